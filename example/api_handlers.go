@@ -0,0 +1,2358 @@
+// Code generated by handlers_gen. DO NOT EDIT.
+
+package example
+
+import "encoding/json"
+import "errors"
+import "fmt"
+import "github.com/ngoryachev/go_api_gen/example/shared"
+import "io"
+import "math"
+import "mime/multipart"
+import "net"
+import "net/http"
+import "net/mail"
+import "net/url"
+import "regexp"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
+import "unicode"
+
+var paramPattern1 = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+var paramPattern2 = regexp.MustCompile(`^[+]?[0-9]{5,15}$`)
+
+var paramJSON1 = map[string]jsonField{
+	"key": {key: "key", kind: "string"},
+}
+
+var paramJSON2 = map[string]jsonField{
+	"login": {key: "login", kind: "string"},
+}
+
+var paramJSON3 = map[string]jsonField{}
+
+var paramJSON4 = map[string]jsonField{
+	"login": {key: "login", kind: "string"},
+}
+
+var paramJSON5 = map[string]jsonField{
+	"login":     {key: "login", kind: "string"},
+	"full_name": {key: "full_name", kind: "string"},
+}
+
+var paramJSON6 = map[string]jsonField{
+	"login":     {key: "login", kind: "string"},
+	"full_name": {key: "full_name", kind: "string"},
+	"age":       {key: "age", kind: "number"},
+}
+
+var paramJSON7 = map[string]jsonField{
+	"login": {key: "login", kind: "string", slice: true},
+	"age":   {key: "age", kind: "number", slice: true},
+}
+
+var paramJSON8 = map[string]jsonField{
+	"login":  {key: "login", kind: "string"},
+	"score":  {key: "score", kind: "number"},
+	"weight": {key: "weight", kind: "number"},
+	"public": {key: "public", kind: "boolean"},
+	"nonce":  {key: "nonce", kind: "number"},
+}
+
+var paramJSON9 = map[string]jsonField{
+	"prefix": {key: "prefix", kind: "string"},
+	"limit":  {key: "limit", kind: "number"},
+	"offset": {key: "offset", kind: "number"},
+	"tenant": {key: "tenant", kind: "string"},
+}
+
+var paramJSON10 = map[string]jsonField{
+	"login":  {key: "login", kind: "string"},
+	"from":   {key: "from", kind: "string"},
+	"to":     {key: "to", kind: "string"},
+	"window": {key: "window", kind: "string"},
+	"since":  {key: "since", kind: "string"},
+}
+
+var paramJSON11 = map[string]jsonField{
+	"login":    {key: "login", kind: "string"},
+	"email":    {key: "email", kind: "string"},
+	"cc":       {key: "cc", kind: "string", slice: true},
+	"reply_to": {key: "reply_to", kind: "string"},
+	"origin":   {key: "origin", kind: "string"},
+	"role":     {key: "role", kind: "string"},
+}
+
+var paramJSON12 = map[string]jsonField{
+	"filter":        {kind: "object"},
+	"filter.status": {key: "filter.status", kind: "string"},
+	"filter.age":    {key: "filter.age", kind: "number"},
+	"filter.login":  {key: "filter.login", kind: "string"},
+	"page":          {kind: "object"},
+	"page.limit":    {key: "page.limit", kind: "number"},
+	"page.offset":   {key: "page.offset", kind: "number"},
+	"sort":          {key: "sort", kind: "string"},
+}
+
+var paramJSON13 = map[string]jsonField{
+	"login": {key: "login", kind: "string"},
+}
+
+var paramJSON14 = map[string]jsonField{
+	"login":           {key: "login", kind: "string"},
+	"role":            {key: "role", kind: "string"},
+	"reason":          {key: "reason", kind: "string"},
+	"email":           {key: "email", kind: "string"},
+	"phone":           {key: "phone", kind: "string"},
+	"password":        {key: "password", kind: "string"},
+	"passwordConfirm": {key: "password_confirm", kind: "string"},
+	"trial_from":      {key: "trial_from", kind: "string"},
+	"trial_to":        {key: "trial_to", kind: "string"},
+	"homepage":        {key: "homepage", kind: "string"},
+	"invite":          {key: "invite", kind: "string"},
+}
+
+func (srv *Registry[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if "/registry/get" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleGet)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	handleServerError(w, http.StatusNotFound, fmt.Errorf("unknown method"))
+}
+func (srv *UserApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if "/user/avatar" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleUploadAvatar))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/import" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleImport))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/profile" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleProfile)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/rename" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleRename))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/update" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleUpdate))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/find" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleFind)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/rate" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleRate))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/list" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleList)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/activity" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleActivity)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/invite" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleInvite))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/search" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleSearch)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/delete" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleDelete))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/signup" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(http.HandlerFunc(srv.handleSignup)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/signup/check" == r.URL.Path {
+		if "POST" == r.Method {
+
+			errorMiddleware(http.HandlerFunc(srv.handleCheckSignup)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if "/user/stats" == r.URL.Path {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleStats)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if _, ok := matchPath("/user/{login}/profile", r.URL.EscapedPath()); ok {
+		if true {
+
+			errorMiddleware(http.HandlerFunc(srv.handleUserProfile)).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	if _, ok := matchPath("/user/{login}/rate/{score}", r.URL.EscapedPath()); ok {
+		if "POST" == r.Method {
+
+			errorMiddleware(authMiddleware(http.HandlerFunc(srv.handleRateUser))).ServeHTTP(w, r)
+
+			return
+		} else {
+			handleServerError(w, http.StatusNotAcceptable, fmt.Errorf("bad method"))
+
+			return
+		}
+	}
+
+	handleServerError(w, http.StatusNotFound, fmt.Errorf("unknown method"))
+}
+
+func (srv *Registry[T]) handleGet(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON1); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := RegistryParams{}
+
+	if sv, ok := formValue(r.Form, "key", "", "", false); ok {
+		params.Key = sv
+	}
+
+	if params.Key == "" {
+		handleBadRequest(w, "key must me not empty")
+
+		return
+	}
+
+	v, err := srv.Get(params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleUploadAvatar(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 1048576, 2097152); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON2); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := AvatarParams{}
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+	params.Avatar = formFile(r, "avatar", "")
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if params.Avatar == nil {
+		handleBadRequest(w, "avatar must me not empty")
+
+		return
+	}
+	if params.Avatar != nil {
+		if params.Avatar.Size > 1048576 {
+			handleServerError(w, http.StatusRequestEntityTooLarge, errors.New("avatar must be at most 1MB"))
+
+			return
+		}
+		if !validContentType(params.Avatar, "image/png", "image/jpeg") {
+			handleBadRequest(w, "avatar content type must be one of [image/png, image/jpeg]")
+
+			return
+		}
+	}
+
+	v, err := srv.UploadAvatar(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleImport(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 33619968); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON3); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := ImportParams{}
+
+	params.Files = formFiles(r, "file", "")
+
+	if len(params.Files) == 0 {
+		handleBadRequest(w, "file must me not empty")
+
+		return
+	}
+	if len(params.Files) > 3 {
+		handleBadRequest(w, "file must have at most 3 items")
+
+		return
+	}
+	for _, item := range params.Files {
+		if item.Size > 65536 {
+			handleServerError(w, http.StatusRequestEntityTooLarge, errors.New("file item must be at most 64KB"))
+
+			return
+		}
+		if !validContentType(item, "text/csv") {
+			handleBadRequest(w, "file item content type must be one of [text/csv]")
+
+			return
+		}
+	}
+
+	v, err := srv.Import(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleProfile(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON4); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := ProfileParams{}
+
+	if sv, ok := formValue(r.Form, "login", "", "", false, "trim", "lower"); ok {
+		params.Login = shared.Login(sv)
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+
+	v, err := srv.Profile(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleUserProfile(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON4); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	bindPathParams(r, "/user/{login}/profile")
+
+	params := ProfileParams{}
+
+	if sv, ok := formValue(r.Form, "login", "", "", false, "trim", "lower"); ok {
+		params.Login = shared.Login(sv)
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+
+	v, err := srv.UserProfile(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleRename(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON5); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := &RenameParams{}
+
+	if sv, ok := formValue(r.Form, "login", "", "", false, "trim", "lower"); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "full_name", "", "", false, "trim", "collapse"); ok {
+		params.Name = sv
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if len(params.Login) < 3 {
+		handleBadRequest(w, "login len must be >= 3")
+
+		return
+	}
+	if params.Name == "" {
+		handleBadRequest(w, "full_name must me not empty")
+
+		return
+	}
+
+	v, err := srv.Rename(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON6); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := UpdateParams{}
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "full_name", "", "", true); ok {
+		val := sv
+		params.Name = &val
+	}
+	if sv, ok := formValue(r.Form, "age", "", "", true); ok {
+		n, err := strconv.ParseInt(sv, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("age", "int", err))
+
+			return
+		}
+		val := int(n)
+		params.Age = &val
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if params.Name != nil {
+		if len(*params.Name) < 1 {
+			handleBadRequest(w, "full_name len must be >= 1")
+
+			return
+		}
+	}
+	if params.Age != nil {
+		if *params.Age < 0 {
+			handleBadRequest(w, "age must be >= 0")
+
+			return
+		}
+		if *params.Age > 150 {
+			handleBadRequest(w, "age must be <= 150")
+
+			return
+		}
+	}
+
+	v, err := srv.Update(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleFind(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON7); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := FindParams{}
+
+	for _, item := range formItems(r.Form, "login", "", "", "") {
+		params.Logins = append(params.Logins, shared.Login(item))
+	}
+	for _, item := range formItems(r.Form, "age", "", ",", "") {
+		n, err := strconv.ParseInt(item, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("age", "int", err))
+
+			return
+		}
+		params.Ages = append(params.Ages, int(n))
+	}
+
+	if len(params.Logins) == 0 {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if len(params.Logins) > 3 {
+		handleBadRequest(w, "login must have at most 3 items")
+
+		return
+	}
+	for _, item := range params.Logins {
+		if len(item) < 3 {
+			handleBadRequest(w, "login item len must be >= 3")
+
+			return
+		}
+	}
+	for _, item := range params.Ages {
+		if item < 0 {
+			handleBadRequest(w, "age item must be >= 0")
+
+			return
+		}
+		if item > 150 {
+			handleBadRequest(w, "age item must be <= 150")
+
+			return
+		}
+	}
+
+	v, err := srv.Find(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleRate(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON8); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := RateParams{}
+	var sentWeight bool
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "score", "", "", false); ok {
+		f, err := parseFloat(sv, 32)
+		if err != nil {
+			handleBadRequest(w, parseError("score", "float32", err))
+
+			return
+		}
+		params.Score = float32(f)
+	}
+	if sv, ok := formValue(r.Form, "weight", "", "1", false); ok {
+		n, err := strconv.ParseUint(sv, 10, 16)
+		if err != nil {
+			handleBadRequest(w, parseError("weight", "uint16", err))
+
+			return
+		}
+		params.Weight = uint16(n)
+		sentWeight = true
+	}
+	if sv, ok := formValue(r.Form, "public", "", "", false); ok {
+		b, err := strconv.ParseBool(sv)
+		if err != nil {
+			handleBadRequest(w, parseError("public", "bool", err))
+
+			return
+		}
+		params.Public = b
+	}
+	if sv, ok := formValue(r.Form, "nonce", "", "", false); ok {
+		n, err := strconv.ParseUint(sv, 10, 64)
+		if err != nil {
+			handleBadRequest(w, parseError("nonce", "uint64", err))
+
+			return
+		}
+		params.Nonce = uint64(n)
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if params.Score == 0 {
+		handleBadRequest(w, "score must me not empty")
+
+		return
+	}
+	if params.Score < 0.5 {
+		handleBadRequest(w, "score must be >= 0.5")
+
+		return
+	}
+	if params.Score > 5 {
+		handleBadRequest(w, "score must be <= 5")
+
+		return
+	}
+	if sentWeight {
+		if params.Weight > 1000 {
+			handleBadRequest(w, "weight must be <= 1000")
+
+			return
+		}
+	}
+
+	v, err := srv.Rate(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleRateUser(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON8); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	bindPathParams(r, "/user/{login}/rate/{score}")
+
+	params := RateParams{}
+	var sentWeight bool
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "score", "", "", false); ok {
+		f, err := parseFloat(sv, 32)
+		if err != nil {
+			handleBadRequest(w, parseError("score", "float32", err))
+
+			return
+		}
+		params.Score = float32(f)
+	}
+	if sv, ok := formValue(r.Form, "weight", "", "1", false); ok {
+		n, err := strconv.ParseUint(sv, 10, 16)
+		if err != nil {
+			handleBadRequest(w, parseError("weight", "uint16", err))
+
+			return
+		}
+		params.Weight = uint16(n)
+		sentWeight = true
+	}
+	if sv, ok := formValue(r.Form, "public", "", "", false); ok {
+		b, err := strconv.ParseBool(sv)
+		if err != nil {
+			handleBadRequest(w, parseError("public", "bool", err))
+
+			return
+		}
+		params.Public = b
+	}
+	if sv, ok := formValue(r.Form, "nonce", "", "", false); ok {
+		n, err := strconv.ParseUint(sv, 10, 64)
+		if err != nil {
+			handleBadRequest(w, parseError("nonce", "uint64", err))
+
+			return
+		}
+		params.Nonce = uint64(n)
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if params.Score == 0 {
+		handleBadRequest(w, "score must me not empty")
+
+		return
+	}
+	if params.Score < 0.5 {
+		handleBadRequest(w, "score must be >= 0.5")
+
+		return
+	}
+	if params.Score > 5 {
+		handleBadRequest(w, "score must be <= 5")
+
+		return
+	}
+	if sentWeight {
+		if params.Weight > 1000 {
+			handleBadRequest(w, "weight must be <= 1000")
+
+			return
+		}
+	}
+
+	v, err := srv.RateUser(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleList(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON9); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := shared.ListParams{}
+	params.TenantScope = &shared.TenantScope{}
+	var sentPagination_Limit bool
+	var sentPagination_Offset bool
+
+	if sv, ok := formValue(r.Form, "prefix", "", "", false); ok {
+		params.Prefix = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "limit", "", "10", false); ok {
+		n, err := strconv.ParseInt(sv, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("limit", "int", err))
+
+			return
+		}
+		params.Pagination.Limit = int(n)
+		sentPagination_Limit = true
+	}
+	if sv, ok := formValue(r.Form, "offset", "", "0", false); ok {
+		n, err := strconv.ParseInt(sv, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("offset", "int", err))
+
+			return
+		}
+		params.Pagination.Offset = int(n)
+		sentPagination_Offset = true
+	}
+	if sv, ok := formValue(r.Form, "tenant", "", "main", false); ok {
+		params.TenantScope.Tenant = sv
+	}
+
+	if sentPagination_Limit {
+		if params.Pagination.Limit < 1 {
+			handleBadRequest(w, "limit must be >= 1")
+
+			return
+		}
+		if params.Pagination.Limit > 100 {
+			handleBadRequest(w, "limit must be <= 100")
+
+			return
+		}
+	}
+	if sentPagination_Offset {
+		if params.Pagination.Offset < 0 {
+			handleBadRequest(w, "offset must be >= 0")
+
+			return
+		}
+		if params.Pagination.Offset > 1000 {
+			handleBadRequest(w, "offset must be <= 1000")
+
+			return
+		}
+	}
+
+	v, err := srv.List(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleActivity(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON10); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := ActivityParams{}
+	var sentWindow bool
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "from", "", "", false); ok {
+		t, err := time.Parse(time.DateOnly, sv)
+		if err != nil {
+			handleBadRequest(w, "from must be formatted as 2006-01-02")
+
+			return
+		}
+		params.From = t
+	}
+	if sv, ok := formValue(r.Form, "to", "", "", true); ok {
+		t, err := time.Parse(time.DateOnly, sv)
+		if err != nil {
+			handleBadRequest(w, "to must be formatted as 2006-01-02")
+
+			return
+		}
+		val := t
+		params.To = &val
+	}
+	if sv, ok := formValue(r.Form, "window", "", "24h", false); ok {
+		d, err := time.ParseDuration(sv)
+		if err != nil {
+			handleBadRequest(w, "window must be a duration like 1h30m")
+
+			return
+		}
+		params.Window = d
+		sentWindow = true
+	}
+	if sv, ok := formValue(r.Form, "since", "", "", true); ok {
+		t, err := time.Parse(time.RFC3339, sv)
+		if err != nil {
+			handleBadRequest(w, "since must be formatted as 2006-01-02T15:04:05Z07:00")
+
+			return
+		}
+		val := t
+		params.Since = &val
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if params.From.IsZero() {
+		handleBadRequest(w, "from must me not empty")
+
+		return
+	}
+	if params.From.Before(time.Unix(1577836800, 0)) {
+		handleBadRequest(w, "from must be >= 2020-01-01")
+
+		return
+	}
+	if sentWindow {
+		if params.Window < 60000000000 {
+			handleBadRequest(w, "window must be >= 1m")
+
+			return
+		}
+		if params.Window > 604800000000000 {
+			handleBadRequest(w, "window must be <= 168h")
+
+			return
+		}
+	}
+	if params.Since != nil {
+		if (*params.Since).Before(time.Unix(1577836800, 0)) {
+			handleBadRequest(w, "since must be >= 2020-01-01T00:00:00Z")
+
+			return
+		}
+	}
+
+	if err := params.Validate(); err != nil {
+		handleValidateError(w, err)
+
+		return
+	}
+
+	v, err := srv.Activity(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleInvite(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON11); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := InviteParams{}
+	var sentEmail bool
+	var sentRole bool
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "email", "", "", false); ok {
+		var val shared.Email
+		err := val.UnmarshalText([]byte(sv))
+		if err != nil {
+			handleBadRequest(w, "email is invalid: "+err.Error())
+
+			return
+		}
+		params.Email = val
+		sentEmail = true
+	}
+	for _, item := range formItems(r.Form, "cc", "", ",", "") {
+		var val shared.Email
+		err := val.UnmarshalText([]byte(item))
+		if err != nil {
+			handleBadRequest(w, "cc is invalid: "+err.Error())
+
+			return
+		}
+		params.Cc = append(params.Cc, val)
+	}
+	if sv, ok := formValue(r.Form, "reply_to", "", "", true); ok {
+		var val shared.Email
+		err := val.UnmarshalText([]byte(sv))
+		if err != nil {
+			handleBadRequest(w, "reply_to is invalid: "+err.Error())
+
+			return
+		}
+		params.ReplyTo = &val
+	}
+	if sv, ok := formValue(r.Form, "origin", "", "", false); ok {
+		var val net.IP
+		err := val.UnmarshalText([]byte(sv))
+		if err != nil {
+			handleBadRequest(w, "origin is invalid: "+err.Error())
+
+			return
+		}
+		params.Origin = val
+	}
+	if sv, ok := formValue(r.Form, "role", "", "user", false); ok {
+		params.Role = Role(sv)
+		sentRole = true
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if !sentEmail {
+		handleBadRequest(w, "email must me not empty")
+
+		return
+	}
+	if len(params.Cc) > 2 {
+		handleBadRequest(w, "cc must have at most 2 items")
+
+		return
+	}
+	if sentRole {
+		if params.Role != "user" && params.Role != "moderator" && params.Role != "admin" {
+			handleBadRequest(w, "role must be one of [user, moderator, admin]")
+
+			return
+		}
+	}
+
+	if err := params.Validate(r.Context()); err != nil {
+		handleValidateError(w, err)
+
+		return
+	}
+
+	v, err := srv.Invite(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleSearch(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON12); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := SearchParams{}
+	params.Page = &shared.Pagination{}
+	var sentFilter_Status bool
+	var sentFilter_Login bool
+	var sentPage_Limit bool
+	var sentPage_Offset bool
+	var sentSort bool
+
+	if sv, ok := formValue(r.Form, "filter.status", "filter[status]", "", false); ok {
+		params.Filter.Status = shared.Status(sv)
+		sentFilter_Status = true
+	}
+	if sv, ok := formValue(r.Form, "filter.age", "filter[age]", "", true); ok {
+		n, err := strconv.ParseInt(sv, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("filter.age", "int", err))
+
+			return
+		}
+		val := int(n)
+		params.Filter.Age = &val
+	}
+	if sv, ok := formValue(r.Form, "filter.login", "filter[login]", "", false); ok {
+		params.Filter.Login = shared.Login(sv)
+		sentFilter_Login = true
+	}
+	if sv, ok := formValue(r.Form, "page.limit", "page[limit]", "10", false); ok {
+		n, err := strconv.ParseInt(sv, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("page.limit", "int", err))
+
+			return
+		}
+		params.Page.Limit = int(n)
+		sentPage_Limit = true
+	}
+	if sv, ok := formValue(r.Form, "page.offset", "page[offset]", "0", false); ok {
+		n, err := strconv.ParseInt(sv, 10, 0)
+		if err != nil {
+			handleBadRequest(w, parseError("page.offset", "int", err))
+
+			return
+		}
+		params.Page.Offset = int(n)
+		sentPage_Offset = true
+	}
+	if sv, ok := formValue(r.Form, "sort", "", "login,asc", false); ok {
+		params.Sort = sv
+		sentSort = true
+	}
+
+	if sentFilter_Status {
+		if params.Filter.Status != "active" && params.Filter.Status != "blocked" {
+			handleBadRequest(w, "filter.status must be one of [active, blocked]")
+
+			return
+		}
+	}
+	if params.Filter.Age != nil {
+		if *params.Filter.Age < 0 {
+			handleBadRequest(w, "filter.age must be >= 0")
+
+			return
+		}
+		if *params.Filter.Age > 150 {
+			handleBadRequest(w, "filter.age must be <= 150")
+
+			return
+		}
+	}
+	if sentFilter_Login {
+		if len(params.Filter.Login) < 2 {
+			handleBadRequest(w, "filter.login len must be >= 2")
+
+			return
+		}
+	}
+	if sentPage_Limit {
+		if params.Page.Limit < 1 {
+			handleBadRequest(w, "page.limit must be >= 1")
+
+			return
+		}
+		if params.Page.Limit > 100 {
+			handleBadRequest(w, "page.limit must be <= 100")
+
+			return
+		}
+	}
+	if sentPage_Offset {
+		if params.Page.Offset < 0 {
+			handleBadRequest(w, "page.offset must be >= 0")
+
+			return
+		}
+		if params.Page.Offset > 1000 {
+			handleBadRequest(w, "page.offset must be <= 1000")
+
+			return
+		}
+	}
+	if sentSort {
+		if params.Sort != "login,asc" && params.Sort != "login,desc" {
+			handleBadRequest(w, "sort must be one of [login,asc, login,desc]")
+
+			return
+		}
+	}
+
+	v, err := srv.Search(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleDelete(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON13); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := DeleteParams{}
+
+	if sv, ok := formValue(r.Form, "login", "", "", false); ok {
+		params.Login = shared.Login(sv)
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+
+	if err := srv.Delete(r.Context(), params); err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *UserApi) handleSignup(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON14); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := SignupParams{}
+	var sentRole bool
+	var sentEmail bool
+	var sentPhone bool
+	var sentPassword bool
+	var sentPasswordConfirm bool
+	var sentInvite bool
+
+	if sv, ok := formValue(r.Form, "login", "", "", false, "trim"); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "role", "", "user", false); ok {
+		params.Role = Role(sv)
+		sentRole = true
+	}
+	if sv, ok := formValue(r.Form, "reason", "", "", false); ok {
+		params.Reason = sv
+	}
+	if sv, ok := formValue(r.Form, "email", "", "", false); ok {
+		params.Email = sv
+		sentEmail = true
+	}
+	if sv, ok := formValue(r.Form, "phone", "", "", false); ok {
+		params.Phone = sv
+		sentPhone = true
+	}
+	if sv, ok := formValue(r.Form, "password", "", "", false); ok {
+		params.Password = sv
+		sentPassword = true
+	}
+	if sv, ok := formValue(r.Form, "password_confirm", "", "", false); ok {
+		params.PasswordConfirm = sv
+		sentPasswordConfirm = true
+	}
+	if sv, ok := formValue(r.Form, "trial_from", "", "", true); ok {
+		t, err := time.Parse(time.DateOnly, sv)
+		if err != nil {
+			handleBadRequest(w, "trial_from must be formatted as 2006-01-02")
+
+			return
+		}
+		val := t
+		params.TrialFrom = &val
+	}
+	if sv, ok := formValue(r.Form, "trial_to", "", "", true); ok {
+		t, err := time.Parse(time.DateOnly, sv)
+		if err != nil {
+			handleBadRequest(w, "trial_to must be formatted as 2006-01-02")
+
+			return
+		}
+		val := t
+		params.TrialTo = &val
+	}
+	if sv, ok := formValue(r.Form, "homepage", "", "", true); ok {
+		val := sv
+		params.Homepage = &val
+	}
+	if sv, ok := formValue(r.Form, "invite", "", "", false); ok {
+		params.Invite = sv
+		sentInvite = true
+	}
+
+	if params.Login == "" {
+		handleBadRequest(w, "login must me not empty")
+
+		return
+	}
+	if len(params.Login) < 3 {
+		handleBadRequest(w, "login len must be >= 3")
+
+		return
+	}
+	if !paramPattern1.MatchString(string(params.Login)) {
+		handleBadRequest(w, "login must match ^[a-z][a-z0-9_]*$")
+
+		return
+	}
+	if sentRole {
+		if params.Role != "user" && params.Role != "moderator" && params.Role != "admin" {
+			handleBadRequest(w, "role must be one of [user, moderator, admin]")
+
+			return
+		}
+	}
+	if params.Role == "admin" && params.Reason == "" {
+		handleBadRequest(w, "reason must me not empty when role is admin")
+
+		return
+	}
+	if sentEmail {
+		if !validFormat("email", params.Email) {
+			handleBadRequest(w, "email must be a valid email address")
+
+			return
+		}
+	}
+	if params.Email == "" && params.Phone == "" {
+		handleBadRequest(w, "phone must me not empty when email is empty")
+
+		return
+	}
+	if sentPhone {
+		if !paramPattern2.MatchString(params.Phone) {
+			handleBadRequest(w, "phone must match ^[+]?[0-9]{5,15}$")
+
+			return
+		}
+	}
+	if params.Password == "" {
+		handleBadRequest(w, "password must me not empty")
+
+		return
+	}
+	if len(params.Password) < 8 {
+		handleBadRequest(w, "password len must be >= 8")
+
+		return
+	}
+	if sentPasswordConfirm && sentPassword && params.PasswordConfirm != params.Password {
+		handleBadRequest(w, "password_confirm must be equal to password")
+
+		return
+	}
+	if params.TrialTo != nil && params.TrialFrom != nil && !(*params.TrialTo).After(*params.TrialFrom) {
+		handleBadRequest(w, "trial_to must be greater than trial_from")
+
+		return
+	}
+	if params.Homepage != nil {
+		if !validFormat("url", *params.Homepage) {
+			handleBadRequest(w, "homepage must be a valid URL")
+
+			return
+		}
+	}
+	if sentInvite {
+		if !validFormat("uuid", params.Invite) {
+			handleBadRequest(w, "invite must be a valid UUID")
+
+			return
+		}
+	}
+
+	v, err := srv.Signup(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleCheckSignup(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if err := parseMultipartForm(w, r, 33554432, 67108864); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	if err := parseJSONForm(w, r, paramJSON14); err != nil {
+		handleBodyError(w, err)
+
+		return
+	}
+
+	params := SignupParams{}
+	var errs []FieldError
+	var sentRole bool
+	var sentEmail bool
+	var sentPhone bool
+	var sentPassword bool
+	var sentPasswordConfirm bool
+	var invalidTrialTo bool
+	var sentInvite bool
+
+	if sv, ok := formValue(r.Form, "login", "", "", false, "trim"); ok {
+		params.Login = shared.Login(sv)
+	}
+	if sv, ok := formValue(r.Form, "role", "", "user", false); ok {
+		params.Role = Role(sv)
+		sentRole = true
+	}
+	if sv, ok := formValue(r.Form, "reason", "", "", false); ok {
+		params.Reason = sv
+	}
+	if sv, ok := formValue(r.Form, "email", "", "", false); ok {
+		params.Email = sv
+		sentEmail = true
+	}
+	if sv, ok := formValue(r.Form, "phone", "", "", false); ok {
+		params.Phone = sv
+		sentPhone = true
+	}
+	if sv, ok := formValue(r.Form, "password", "", "", false); ok {
+		params.Password = sv
+		sentPassword = true
+	}
+	if sv, ok := formValue(r.Form, "password_confirm", "", "", false); ok {
+		params.PasswordConfirm = sv
+		sentPasswordConfirm = true
+	}
+	if sv, ok := formValue(r.Form, "trial_from", "", "", true); ok {
+		t, err := time.Parse(time.DateOnly, sv)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "trial_from", Rule: "type", Message: "trial_from must be formatted as 2006-01-02"})
+		} else {
+			val := t
+			params.TrialFrom = &val
+		}
+	}
+	if sv, ok := formValue(r.Form, "trial_to", "", "", true); ok {
+		t, err := time.Parse(time.DateOnly, sv)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "trial_to", Rule: "type", Message: "trial_to must be formatted as 2006-01-02"})
+			invalidTrialTo = true
+		} else {
+			val := t
+			params.TrialTo = &val
+		}
+	}
+	if sv, ok := formValue(r.Form, "homepage", "", "", true); ok {
+		val := sv
+		params.Homepage = &val
+	}
+	if sv, ok := formValue(r.Form, "invite", "", "", false); ok {
+		params.Invite = sv
+		sentInvite = true
+	}
+
+	func() {
+		if params.Login == "" {
+			errs = append(errs, FieldError{Field: "login", Rule: "required", Message: "login must me not empty"})
+
+			return
+		}
+		if len(params.Login) < 3 {
+			errs = append(errs, FieldError{Field: "login", Rule: "min", Message: "login len must be >= 3"})
+
+			return
+		}
+		if !paramPattern1.MatchString(string(params.Login)) {
+			errs = append(errs, FieldError{Field: "login", Rule: "pattern", Message: "login must match ^[a-z][a-z0-9_]*$"})
+
+			return
+		}
+	}()
+	func() {
+		if sentRole {
+			if params.Role != "user" && params.Role != "moderator" && params.Role != "admin" {
+				errs = append(errs, FieldError{Field: "role", Rule: "enum", Message: "role must be one of [user, moderator, admin]"})
+
+				return
+			}
+		}
+	}()
+	func() {
+		if params.Role == "admin" && params.Reason == "" {
+			errs = append(errs, FieldError{Field: "reason", Rule: "required_if", Message: "reason must me not empty when role is admin"})
+
+			return
+		}
+	}()
+	func() {
+		if sentEmail {
+			if !validFormat("email", params.Email) {
+				errs = append(errs, FieldError{Field: "email", Rule: "format", Message: "email must be a valid email address"})
+
+				return
+			}
+		}
+	}()
+	func() {
+		if params.Email == "" && params.Phone == "" {
+			errs = append(errs, FieldError{Field: "phone", Rule: "required_without", Message: "phone must me not empty when email is empty"})
+
+			return
+		}
+		if sentPhone {
+			if !paramPattern2.MatchString(params.Phone) {
+				errs = append(errs, FieldError{Field: "phone", Rule: "pattern", Message: "phone must match ^[+]?[0-9]{5,15}$"})
+
+				return
+			}
+		}
+	}()
+	func() {
+		if params.Password == "" {
+			errs = append(errs, FieldError{Field: "password", Rule: "required", Message: "password must me not empty"})
+
+			return
+		}
+		if len(params.Password) < 8 {
+			errs = append(errs, FieldError{Field: "password", Rule: "min", Message: "password len must be >= 8"})
+
+			return
+		}
+	}()
+	func() {
+		if sentPasswordConfirm && sentPassword && params.PasswordConfirm != params.Password {
+			errs = append(errs, FieldError{Field: "password_confirm", Rule: "eqfield", Message: "password_confirm must be equal to password"})
+
+			return
+		}
+	}()
+	if !invalidTrialTo {
+		func() {
+			if params.TrialTo != nil && params.TrialFrom != nil && !(*params.TrialTo).After(*params.TrialFrom) {
+				errs = append(errs, FieldError{Field: "trial_to", Rule: "gtfield", Message: "trial_to must be greater than trial_from"})
+
+				return
+			}
+		}()
+	}
+	func() {
+		if params.Homepage != nil {
+			if !validFormat("url", *params.Homepage) {
+				errs = append(errs, FieldError{Field: "homepage", Rule: "format", Message: "homepage must be a valid URL"})
+
+				return
+			}
+		}
+	}()
+	func() {
+		if sentInvite {
+			if !validFormat("uuid", params.Invite) {
+				errs = append(errs, FieldError{Field: "invite", Rule: "format", Message: "invite must be a valid UUID"})
+
+				return
+			}
+		}
+	}()
+
+	if len(errs) > 0 {
+		handleFieldErrors(w, errs)
+
+		return
+	}
+
+	v, err := srv.CheckSignup(r.Context(), params)
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func (srv *UserApi) handleStats(w http.ResponseWriter, r *http.Request) {
+
+	v, err := srv.Stats()
+
+	if err != nil {
+		handleMethodError(w, err)
+
+		return
+	}
+
+	handleServerResponse(w, v)
+}
+
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Println("authMiddleware", r.URL.Path)
+		xAuthHeader := r.Header.Get("X-Auth")
+
+		if xAuthHeader != "100500" {
+			fmt.Println("no auth at", r.URL.Path)
+
+			handleServerError(w, http.StatusForbidden, fmt.Errorf("unauthorized"))
+
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func errorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Println("errorMiddleware", r.URL.Path)
+		defer func() {
+			if err := recover(); err != nil {
+				fmt.Println("recovered", err)
+
+				e := fmt.Errorf("%s", err)
+				handleServerError(w, http.StatusInternalServerError, e)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+type ServerResponse struct {
+	Error    string       `json:"error"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	Response interface{}  `json:"response,omitempty"`
+}
+
+// FieldError is an invalid param of a handler reporting all of them: the
+// param name, the failed rule and the message
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (sr ServerResponse) Marshal() []byte {
+	b, _ := json.Marshal(sr)
+
+	return b
+}
+
+func handleServerError(w http.ResponseWriter, httpStatus int, err error) {
+	w.WriteHeader(httpStatus)
+	w.Write(ServerResponse{
+		Error: err.Error(),
+	}.Marshal())
+}
+
+func handleServerResponse(w http.ResponseWriter, response interface{}) {
+	//w.WriteHeader(http.StatusOK)
+	w.Write(ServerResponse{
+		Error:    "",
+		Response: response,
+	}.Marshal())
+}
+
+func handleBadRequest(w http.ResponseWriter, message string) {
+	handleServerError(w, http.StatusBadRequest, errors.New(message))
+}
+
+// handleFieldErrors answers 400 with all the invalid params, the first one
+// is the error as well, like in the handlers stopping at it
+func handleFieldErrors(w http.ResponseWriter, errs []FieldError) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(ServerResponse{
+		Error:  errs[0].Message,
+		Errors: errs,
+	}.Marshal())
+}
+
+// handleMethodError answers with the status of an ApiError returned by the
+// method, any other error is an internal one
+func handleMethodError(w http.ResponseWriter, err error) {
+	var apiErr ApiError
+	if errors.As(err, &apiErr) {
+		handleServerError(w, apiErr.HTTPStatus, apiErr.Err)
+
+		return
+	}
+
+	handleServerError(w, http.StatusInternalServerError, err)
+}
+
+// handleValidateError answers with the status of an ApiError returned by
+// the Validate hook of the parameters, any other error is a bad request
+func handleValidateError(w http.ResponseWriter, err error) {
+	var apiErr ApiError
+	if errors.As(err, &apiErr) {
+		handleServerError(w, apiErr.HTTPStatus, apiErr.Err)
+
+		return
+	}
+
+	handleServerError(w, http.StatusBadRequest, err)
+}
+
+// handleBodyError answers 413 to a body over its limit, any other problem
+// of the body is a bad request
+func handleBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		handleServerError(w, http.StatusRequestEntityTooLarge, errors.New("request body is too large"))
+
+		return
+	}
+
+	handleServerError(w, http.StatusBadRequest, err)
+}
+
+// formValue is the first value of the param key, a nested param may be spelled
+// with brackets as well: filter.status or filter[status]. The value is
+// normalized first, then an empty one is missing unless keepEmpty, which
+// pointer fields set to tell an empty value from a missing one, and def, if
+// any, stands for a missing value
+func formValue(values url.Values, key, bracketKey, def string, keepEmpty bool, normalize ...string) (string, bool) {
+	vs, ok := values[key]
+	if !ok && bracketKey != "" {
+		vs, ok = values[bracketKey]
+	}
+
+	if ok && len(vs) > 0 {
+		if sv := normalizeValue(vs[0], normalize); sv != "" || keepEmpty {
+			return sv, true
+		}
+	}
+
+	return def, def != ""
+}
+
+// formItems lists the items of the repeated param key, each value may hold
+// several items separated by split; the items are normalized, empty ones
+// are dropped and def, if any, stands for a missing param
+func formItems(values url.Values, key, bracketKey, split, def string, normalize ...string) []string {
+	raw := values[key]
+	if bracketKey != "" {
+		raw = append(raw[:len(raw):len(raw)], values[bracketKey]...)
+	}
+
+	if len(raw) == 0 && def != "" {
+		raw = []string{def}
+	}
+
+	var items []string
+
+	for _, sv := range raw {
+		if split == "" {
+			if sv = normalizeValue(sv, normalize); sv != "" {
+				items = append(items, sv)
+			}
+
+			continue
+		}
+
+		for _, item := range strings.Split(sv, split) {
+			if item = normalizeValue(item, normalize); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// normalizeValue applies the normalization options of apivalidator to a
+// param value in the order they are given: trim, collapse, lower, upper
+func normalizeValue(s string, options []string) string {
+	for _, option := range options {
+		switch option {
+		case "trim":
+			s = strings.TrimSpace(s)
+		case "collapse":
+			s = collapseSpaces(s)
+		case "lower":
+			s = strings.ToLower(s)
+		case "upper":
+			s = strings.ToUpper(s)
+		}
+	}
+
+	return s
+}
+
+// collapseSpaces replaces every run of white space in s with a single space
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	space := false
+
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+
+			space = true
+
+			continue
+		}
+
+		space = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// jsonField is a key of a JSON body bound to the param key, kind is the
+// JSON type of its values: string, number, boolean or object for a nested
+// struct, and slice tells that the values come in an array
+type jsonField struct {
+	key   string
+	kind  string
+	slice bool
+}
+
+// maxJSONBody limits the size of a JSON body
+const maxJSONBody = 1 << 20
+
+// parseJSONForm reads a JSON object body into r.Form, so that the params are
+// bound the same way as the form ones: the values of fields go to their
+// param keys, nested objects hold the params of nested structs and arrays
+// the items of slices. Other content types are left to r.ParseForm
+func parseJSONForm(w http.ResponseWriter, r *http.Request, fields map[string]jsonField) error {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	dec.UseNumber()
+
+	var body interface{}
+	if err := dec.Decode(&body); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid JSON body: unexpected data after the object")
+	}
+
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		return errors.New("JSON body must be an object")
+	}
+
+	if r.Form == nil {
+		r.Form = url.Values{}
+	}
+
+	return bindJSONObject(r.Form, fields, "", object)
+}
+
+// bindJSONObject sets the form values from the fields of object, prefix is
+// the path of the object in the body. A null is a missing value
+func bindJSONObject(form url.Values, fields map[string]jsonField, prefix string, object map[string]interface{}) error {
+	// in order, so that the same body gets the same error
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		value := object[name]
+
+		field, ok := fields[path]
+		if !ok {
+			return fmt.Errorf("unknown field %s", path)
+		}
+
+		if value == nil {
+			continue
+		}
+
+		switch {
+		case field.kind == "object":
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be an object", path)
+			}
+
+			if err := bindJSONObject(form, fields, path+".", nested); err != nil {
+				return err
+			}
+		case field.slice:
+			items, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be an array", path)
+			}
+
+			values := make([]string, 0, len(items))
+
+			for _, item := range items {
+				sv, ok := jsonScalar(item, field.kind)
+				if !ok {
+					return fmt.Errorf("%s must be an array of %ss", path, field.kind)
+				}
+
+				values = append(values, sv)
+			}
+
+			form[field.key] = values
+		default:
+			sv, ok := jsonScalar(value, field.kind)
+			if !ok {
+				return fmt.Errorf("%s must be a %s", path, field.kind)
+			}
+
+			form[field.key] = []string{sv}
+		}
+	}
+
+	return nil
+}
+
+// jsonScalar is the form value of a decoded JSON value of the kind
+func jsonScalar(value interface{}, kind string) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, kind == "string"
+	case json.Number:
+		return v.String(), kind == "number"
+	case bool:
+		return strconv.FormatBool(v), kind == "boolean"
+	}
+
+	return "", false
+}
+
+// parseMultipartForm reads a multipart/form-data body into r.Form and the
+// files into r.MultipartForm, keeping up to maxMemory bytes of them in
+// memory and the rest in temporary files. A body over maxBody is cut off
+// with an *http.MaxBytesError. Other content types are left to r.ParseForm
+func parseMultipartForm(w http.ResponseWriter, r *http.Request, maxMemory, maxBody int64) error {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+
+	if !strings.EqualFold(strings.TrimSpace(mediaType), "multipart/form-data") {
+		return nil
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return fmt.Errorf("invalid multipart body: %w", err)
+	}
+
+	return nil
+}
+
+// formFiles lists the files uploaded as the param key, spelled with brackets
+// as well for a nested param
+func formFiles(r *http.Request, key, bracketKey string) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+
+	files := r.MultipartForm.File[key]
+	if bracketKey != "" {
+		files = append(files[:len(files):len(files)], r.MultipartForm.File[bracketKey]...)
+	}
+
+	return files
+}
+
+// formFile is the first file uploaded as the param key, nil if there is none
+func formFile(r *http.Request, key, bracketKey string) *multipart.FileHeader {
+	if files := formFiles(r, key, bracketKey); len(files) > 0 {
+		return files[0]
+	}
+
+	return nil
+}
+
+// validContentType tells whether the content type the client sent for the
+// file is one of allowed, image/* allows any image
+func validContentType(file *multipart.FileHeader, allowed ...string) bool {
+	mediaType, _, _ := strings.Cut(file.Header.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, t := range allowed {
+		if t == mediaType || strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPath matches the escaped path against the url of a method, whose
+// {name} segments match any non-empty segment; the values of the segments
+// are unescaped and keyed by name
+func matchPath(pattern, path string) (url.Values, bool) {
+	patternSegments, segments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(patternSegments) != len(segments) {
+		return nil, false
+	}
+
+	values := url.Values{}
+
+	for i, p := range patternSegments {
+		if !strings.HasPrefix(p, "{") {
+			if p != segments[i] {
+				return nil, false
+			}
+
+			continue
+		}
+
+		v, err := url.PathUnescape(segments[i])
+		if err != nil || v == "" {
+			return nil, false
+		}
+
+		values.Set(p[1:len(p)-1], v)
+	}
+
+	return values, true
+}
+
+// bindPathParams sets the params of the {name} segments of the url in
+// r.Form, a path value overrides the query and body ones of the same name
+func bindPathParams(r *http.Request, pattern string) {
+	values, _ := matchPath(pattern, r.URL.EscapedPath())
+
+	if r.Form == nil {
+		r.Form = url.Values{}
+	}
+
+	for name, vs := range values {
+		r.Form[name] = vs
+	}
+}
+
+// parseFloat is strconv.ParseFloat rejecting NaN and the infinities, which
+// would pass the required, min and max checks and can't be written as JSON
+func parseFloat(s string, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return 0, strconv.ErrSyntax
+	}
+
+	return f, err
+}
+
+// parseError is the message about a param value strconv failed to parse as
+// typeName
+func parseError(paramName, typeName string, err error) string {
+	if errors.Is(err, strconv.ErrRange) {
+		return paramName + " is out of " + typeName + " range"
+	}
+
+	return paramName + " must be " + typeName
+}
+
+// validFormat tells whether s is written in the format named by the format
+// option of apivalidator
+func validFormat(format, s string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+
+		return err == nil && addr.Address == s
+	case "uuid":
+		if len(s) != 36 {
+			return false
+		}
+
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+
+			switch {
+			case i == 8 || i == 13 || i == 18 || i == 23:
+				if c != '-' {
+					return false
+				}
+			case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+			default:
+				return false
+			}
+		}
+
+		return true
+	case "url":
+		u, err := url.ParseRequestURI(s)
+
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case "ip":
+		return net.ParseIP(s) != nil
+	case "hostname":
+		if len(s) == 0 || len(s) > 253 {
+			return false
+		}
+
+		for _, label := range strings.Split(s, ".") {
+			if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+				return false
+			}
+
+			for i := 0; i < len(label); i++ {
+				c := label[i]
+				if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-') {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,689 @@
+package example
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Case struct {
+	Method string
+	Path   string
+	Query  string
+	// a JSON body posted instead of the form
+	JSON string
+	// files posted in a multipart body along with the Query params
+	Files  []File
+	Auth   bool
+	Status int
+	Result interface{}
+}
+
+// CaseResponse
+type CR map[string]interface{}
+
+// File is an uploaded file of a Case.
+type File struct {
+	Key, Name, ContentType, Content string
+}
+
+// multipartBody encodes the query params and the files of a multipart body.
+func multipartBody(query string, files []File) (io.Reader, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	values, _ := url.ParseQuery(query)
+
+	for key, vs := range values {
+		for _, v := range vs {
+			mw.WriteField(key, v)
+		}
+	}
+
+	for _, f := range files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="`+f.Key+`"; filename="`+f.Name+`"`)
+		header.Set("Content-Type", f.ContentType)
+
+		part, _ := mw.CreatePart(header)
+		io.WriteString(part, f.Content)
+	}
+
+	mw.Close()
+
+	return body, mw.FormDataContentType()
+}
+
+func runCases(t *testing.T, handler http.Handler, cases []Case) {
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	for idx, c := range cases {
+		var req *http.Request
+		var err error
+
+		if c.Files != nil {
+			body, contentType := multipartBody(c.Query, c.Files)
+			req, err = http.NewRequest(http.MethodPost, ts.URL+c.Path, body)
+			req.Header.Set("Content-Type", contentType)
+		} else if c.JSON != "" {
+			req, err = http.NewRequest(http.MethodPost, ts.URL+c.Path, strings.NewReader(c.JSON))
+			req.Header.Set("Content-Type", "application/json")
+		} else if c.Method == http.MethodPost {
+			req, err = http.NewRequest(c.Method, ts.URL+c.Path, strings.NewReader(c.Query))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req, err = http.NewRequest(c.Method, ts.URL+c.Path+"?"+c.Query, nil)
+		}
+
+		if err != nil {
+			t.Fatalf("[%d] cant build request: %v", idx, err)
+		}
+
+		if c.Auth {
+			req.Header.Set("X-Auth", "100500")
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("[%d] request error: %v", idx, err)
+			continue
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != c.Status {
+			t.Errorf("[%d] %s?%s: expected http status %v, got %v (%s)", idx, c.Path, c.Query, c.Status, resp.StatusCode, body)
+			continue
+		}
+
+		// 204 No Content
+		if c.Result == nil && len(body) == 0 {
+			continue
+		}
+
+		var result, expected interface{}
+
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Errorf("[%d] cant unpack json %q: %v", idx, body, err)
+			continue
+		}
+
+		data, _ := json.Marshal(c.Result)
+		json.Unmarshal(data, &expected)
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("[%d] %s?%s: results not match\nGot: %#v\nExpected: %#v", idx, c.Path, c.Query, result, expected)
+		}
+	}
+}
+
+func TestUserApi(t *testing.T) {
+	runCases(t, NewUserApi(), []Case{
+		{
+			Path:   "/user/profile",
+			Query:  "login=rvasily",
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"login": "rvasily", "full_name": "Vasily Romanov"},
+			},
+		},
+		{
+			Path:   "/user/profile",
+			Query:  "login=nobody",
+			Status: http.StatusNotFound,
+			Result: CR{"error": "user not exist"},
+		},
+		{
+			Path:   "/user/rename",
+			Method: http.MethodPost,
+			Query:  "login=rvasily&full_name=Vasily",
+			Auth:   true,
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"login": "rvasily", "full_name": "Vasily"},
+			},
+		},
+		{
+			Path:   "/user/list",
+			Query:  "prefix=r",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{"tenant": "main", "users": []CR{
+					{"login": "rmaria", "full_name": "Maria Romanova"},
+					{"login": "rvasily", "full_name": "Vasily"},
+				}},
+			},
+		},
+		{
+			Path:   "/user/list",
+			Query:  "prefix=r&limit=1&offset=1&tenant=eu",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{"tenant": "eu", "users": []CR{
+					{"login": "rvasily", "full_name": "Vasily"},
+				}},
+			},
+		},
+	})
+}
+
+func TestOptionalParams(t *testing.T) {
+	runCases(t, NewUserApi(), []Case{
+		{ // only age is sent, the name stays
+			Path:   "/user/update",
+			Method: http.MethodPost,
+			Query:  "login=ivan&age=0",
+			Auth:   true,
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"login": "ivan", "full_name": "Ivan Ivanov"},
+			},
+		},
+		{
+			Path:   "/user/update",
+			Method: http.MethodPost,
+			Query:  "login=ivan&age=33",
+			Auth:   true,
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"login": "ivan", "full_name": "Ivan Ivanov", "age": 33},
+			},
+		},
+		{ // only the name is sent, the age stays
+			Path:   "/user/update",
+			Method: http.MethodPost,
+			Query:  "login=ivan&full_name=Ivan",
+			Auth:   true,
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"login": "ivan", "full_name": "Ivan", "age": 33},
+			},
+		},
+		{ // an empty value is sent, not missing
+			Path:   "/user/update",
+			Method: http.MethodPost,
+			Query:  "login=ivan&full_name=",
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "full_name len must be >= 1"},
+		},
+		{
+			Path:   "/user/update",
+			Method: http.MethodPost,
+			Query:  "login=ivan&age=-1",
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "age must be >= 0"},
+		},
+		{
+			Path:   "/user/update",
+			Method: http.MethodPost,
+			Query:  "login=ivan&age=151",
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "age must be <= 150"},
+		},
+	})
+}
+
+func TestSliceParams(t *testing.T) {
+	runCases(t, NewUserApi(), []Case{
+		{
+			Path:   "/user/find",
+			Query:  "login=ivan&login=rvasily&login=nobody",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{"users": []CR{
+					{"login": "ivan", "full_name": "Ivan Ivanov"},
+					{"login": "rvasily", "full_name": "Vasily Romanov"},
+				}, "tenant": ""},
+			},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "login=ivan&login=rvasily&age=1,0",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{"users": []CR{
+					{"login": "ivan", "full_name": "Ivan Ivanov"},
+					{"login": "rvasily", "full_name": "Vasily Romanov"},
+				}, "tenant": ""},
+			},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "login=ivan&age=1&age=2",
+			Status: http.StatusOK,
+			Result: CR{
+				"error":    "",
+				"response": CR{"users": []CR{}, "tenant": ""},
+			},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "age=1",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "login must me not empty"},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "login=a&login=b&login=c&login=d",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "login must have at most 3 items"},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "login=ivan&login=ab",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "login item len must be >= 3"},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "login=ivan&age=20,-1",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "age item must be >= 0"},
+		},
+		{
+			Path:   "/user/find",
+			Query:  "login=ivan&age=20,x",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "age must be int"},
+		},
+	})
+}
+
+func TestScalarParams(t *testing.T) {
+	rate := func(query string, status int, result interface{}) Case {
+		return Case{Path: "/user/rate", Method: http.MethodPost, Auth: true, Query: query, Status: status, Result: result}
+	}
+
+	runCases(t, NewUserApi(), []Case{
+		rate("login=ivan&score=4.5", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "score": 4.5, "weight": 1, "public": false, "nonce": 0},
+		}),
+		rate("login=ivan&score=1&weight=1000&public=true&nonce=18446744073709551615", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "score": 1, "weight": 1000, "public": true, "nonce": uint64(18446744073709551615)},
+		}),
+		rate("login=ivan", http.StatusBadRequest, CR{"error": "score must me not empty"}),
+		rate("login=ivan&score=0.1", http.StatusBadRequest, CR{"error": "score must be >= 0.5"}),
+		rate("login=ivan&score=5.5", http.StatusBadRequest, CR{"error": "score must be <= 5"}),
+		rate("login=ivan&score=five", http.StatusBadRequest, CR{"error": "score must be float32"}),
+		// NaN would pass the min and max checks
+		rate("login=ivan&score=NaN", http.StatusBadRequest, CR{"error": "score must be float32"}),
+		rate("login=ivan&score=Inf", http.StatusBadRequest, CR{"error": "score must be float32"}),
+		rate("login=ivan&score=-infinity", http.StatusBadRequest, CR{"error": "score must be float32"}),
+		rate("login=ivan&score=1e39", http.StatusBadRequest, CR{"error": "score is out of float32 range"}),
+		rate("login=ivan&score=1&weight=1001", http.StatusBadRequest, CR{"error": "weight must be <= 1000"}),
+		rate("login=ivan&score=1&weight=70000", http.StatusBadRequest, CR{"error": "weight is out of uint16 range"}),
+		rate("login=ivan&score=1&weight=-1", http.StatusBadRequest, CR{"error": "weight must be uint16"}),
+		rate("login=ivan&score=1&public=yes", http.StatusBadRequest, CR{"error": "public must be bool"}),
+		rate("login=ivan&score=1&nonce=18446744073709551616", http.StatusBadRequest, CR{"error": "nonce is out of uint64 range"}),
+	})
+}
+
+func TestTimeParams(t *testing.T) {
+	activity := func(query string, status int, result interface{}) Case {
+		return Case{Path: "/user/activity", Query: query, Status: status, Result: result}
+	}
+
+	runCases(t, NewUserApi(), []Case{
+		activity("login=ivan&from=2024-01-31", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "from": "2024-01-31T00:00:00Z", "to": "2024-02-01T00:00:00Z", "window": "24h0m0s"},
+		}),
+		activity("login=ivan&from=2024-01-31&to=2024-03-01", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "from": "2024-01-31T00:00:00Z", "to": "2024-03-01T00:00:00Z", "window": "24h0m0s"},
+		}),
+		activity("login=ivan&from=2024-01-31&window=1h30m", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "from": "2024-01-31T00:00:00Z", "to": "2024-01-31T01:30:00Z", "window": "1h30m0s"},
+		}),
+		activity("login=ivan", http.StatusBadRequest, CR{"error": "from must me not empty"}),
+		activity("login=ivan&from=2024-01-31&to=2024-01-30", http.StatusBadRequest, CR{"error": "to must be after from"}),
+		activity("login=ivan&from=31.01.2024", http.StatusBadRequest, CR{"error": "from must be formatted as 2006-01-02"}),
+		activity("login=ivan&from=2019-12-31", http.StatusBadRequest, CR{"error": "from must be >= 2020-01-01"}),
+		activity("login=ivan&from=2024-01-31&to=tomorrow", http.StatusBadRequest, CR{"error": "to must be formatted as 2006-01-02"}),
+		activity("login=ivan&from=2024-01-31&window=day", http.StatusBadRequest, CR{"error": "window must be a duration like 1h30m"}),
+		activity("login=ivan&from=2024-01-31&window=30s", http.StatusBadRequest, CR{"error": "window must be >= 1m"}),
+		activity("login=ivan&from=2024-01-31&window=200h", http.StatusBadRequest, CR{"error": "window must be <= 168h"}),
+		// RFC 3339 without a layout
+		activity("login=ivan&from=2024-01-31&since=2024-01-02T00:00:00Z", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "from": "2024-01-31T00:00:00Z", "to": "2024-02-01T00:00:00Z", "window": "24h0m0s", "since": "2024-01-02T00:00:00Z"},
+		}),
+		activity("login=ivan&from=2024-01-31&since=2024-01-02", http.StatusBadRequest, CR{"error": "since must be formatted as 2006-01-02T15:04:05Z07:00"}),
+		activity("login=ivan&from=2024-01-31&since=2019-12-31T23:59:59Z", http.StatusBadRequest, CR{"error": "since must be >= 2020-01-01T00:00:00Z"}),
+	})
+}
+
+func TestTextUnmarshalerParams(t *testing.T) {
+	invite := func(query string, status int, result interface{}) Case {
+		return Case{Path: "/user/invite", Method: http.MethodPost, Auth: true, Query: query, Status: status, Result: result}
+	}
+
+	runCases(t, NewUserApi(), []Case{
+		invite("login=ivan&email=Ivan@Example.com", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "email": "ivan@example.com", "cc": []interface{}{}, "reply_to": "ivan@example.com", "origin": "", "role": "user"},
+		}),
+		invite("login=ivan&email=ivan@example.com&cc=a@b.c,d@e.f&reply_to=boss@example.com&origin=10.0.0.1&role=admin", http.StatusOK, CR{
+			"error": "",
+			"response": CR{
+				"login":    "ivan",
+				"email":    "ivan@example.com",
+				"cc":       []interface{}{"a@b.c", "d@e.f"},
+				"reply_to": "boss@example.com",
+				"origin":   "10.0.0.1",
+				"role":     "admin",
+			},
+		}),
+		invite("login=ivan", http.StatusBadRequest, CR{"error": "email must me not empty"}),
+		invite("login=ivan&email=ivan@example.com&role=root", http.StatusBadRequest, CR{"error": "role must be one of [user, moderator, admin]"}),
+		invite("login=ivan&email=ivan", http.StatusBadRequest, CR{"error": `email is invalid: "ivan" is not an email`}),
+		invite("login=ivan&email=ivan@example.com&cc=a@b.c,d", http.StatusBadRequest, CR{"error": `cc is invalid: "d" is not an email`}),
+		invite("login=ivan&email=ivan@example.com&cc=a@b.c,d@e.f,g@h.i", http.StatusBadRequest, CR{"error": "cc must have at most 2 items"}),
+		invite("login=ivan&email=ivan@example.com&cc=a@b.c,IVAN@example.com", http.StatusUnprocessableEntity, CR{"error": "cc must not repeat email"}),
+		invite("login=ivan&email=ivan@example.com&reply_to=", http.StatusBadRequest, CR{"error": `reply_to is invalid: "" is not an email`}),
+		invite("login=ivan&email=ivan@example.com&origin=localhost", http.StatusBadRequest, CR{"error": "origin is invalid: invalid IP address: localhost"}),
+	})
+}
+
+func TestNestedParams(t *testing.T) {
+	search := func(query string, status int, result interface{}) Case {
+		return Case{Path: "/user/search", Query: query, Status: status, Result: result}
+	}
+
+	runCases(t, NewUserApi(), []Case{
+		search("", http.StatusOK, CR{
+			"error": "",
+			"response": CR{"filter": "status= login=", "limit": 10, "users": []interface{}{
+				CR{"login": "ivan", "full_name": "Ivan Ivanov"},
+				CR{"login": "rmaria", "full_name": "Maria Romanova"},
+				CR{"login": "rvasily", "full_name": "Vasily Romanov"},
+			}},
+		}),
+		search("filter.status=active&filter.age=30&filter.login=rv&page.limit=1", http.StatusOK, CR{
+			"error": "",
+			"response": CR{"filter": "status=active login=rv age=30", "limit": 1, "users": []interface{}{
+				CR{"login": "rvasily", "full_name": "Vasily Romanov"},
+			}},
+		}),
+		search("filter[status]=blocked&filter[login]=iv&page[limit]=5", http.StatusOK, CR{
+			"error": "",
+			"response": CR{"filter": "status=blocked login=iv", "limit": 5, "users": []interface{}{
+				CR{"login": "ivan", "full_name": "Ivan Ivanov"},
+			}},
+		}),
+		search("page.limit=2&sort=login,desc", http.StatusOK, CR{
+			"error": "",
+			"response": CR{"filter": "status= login=", "limit": 2, "users": []interface{}{
+				CR{"login": "rvasily", "full_name": "Vasily Romanov"},
+				CR{"login": "rmaria", "full_name": "Maria Romanova"},
+			}},
+		}),
+		search("sort=login", http.StatusBadRequest, CR{"error": "sort must be one of [login,asc, login,desc]"}),
+		search("filter.status=deleted", http.StatusBadRequest, CR{"error": "filter.status must be one of [active, blocked]"}),
+		search("filter[age]=-1", http.StatusBadRequest, CR{"error": "filter.age must be >= 0"}),
+		search("filter.age=old", http.StatusBadRequest, CR{"error": "filter.age must be int"}),
+		search("filter.login=r", http.StatusBadRequest, CR{"error": "filter.login len must be >= 2"}),
+		search("page.limit=0", http.StatusBadRequest, CR{"error": "page.limit must be >= 1"}),
+	})
+}
+
+func TestMethodSignatures(t *testing.T) {
+	api := NewUserApi()
+
+	runCases(t, api, []Case{
+		{Path: "/user/stats", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"users": 3}}},
+		{Path: "/user/delete", Method: http.MethodPost, Auth: true, Query: "login=ivan", Status: http.StatusNoContent},
+		{Path: "/user/delete", Method: http.MethodPost, Auth: true, Query: "login=ivan", Status: http.StatusNotFound, Result: CR{"error": "user not exist"}},
+		{Path: "/user/delete", Method: http.MethodPost, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "login must me not empty"}},
+		{Path: "/user/stats", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"users": 2}}},
+	})
+
+	runCases(t, NewRegistry(map[string]int{"answer": 42}), []Case{
+		{Path: "/registry/get", Query: "key=answer", Status: http.StatusOK, Result: CR{"error": "", "response": 42}},
+		{Path: "/registry/get", Query: "key=question", Status: http.StatusNotFound, Result: CR{"error": "question not found"}},
+	})
+}
+
+func TestCrossFieldParams(t *testing.T) {
+	api := NewUserApi()
+	password := "&password=secret12&password_confirm=secret12"
+
+	runCases(t, api, []Case{
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=petr&email=petr@mail.ru" + password, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "petr", "full_name": ""}}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&phone=100500" + password, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "olga", "full_name": ""}}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=anna&role=admin&email=anna@mail.ru" + password, Status: http.StatusBadRequest, Result: CR{"error": "reason must me not empty when role is admin"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=anna&role=admin&reason=ops&email=anna@mail.ru" + password, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "anna", "full_name": ""}}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=oleg" + password, Status: http.StatusBadRequest, Result: CR{"error": "phone must me not empty when email is empty"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=oleg&email=oleg@mail.ru&password=secret12&password_confirm=secret13", Status: http.StatusBadRequest, Result: CR{"error": "password_confirm must be equal to password"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=oleg&email=oleg@mail.ru" + password + "&trial_from=2024-02-01&trial_to=2024-02-01", Status: http.StatusBadRequest, Result: CR{"error": "trial_to must be greater than trial_from"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=oleg&email=oleg@mail.ru" + password + "&trial_from=2024-02-01&trial_to=2024-03-01", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "oleg", "full_name": ""}}},
+		// the errors follow the field order: login before phone before password
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=ab&password=short", Status: http.StatusBadRequest, Result: CR{"error": "login len must be >= 3"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=ivan2&password=short", Status: http.StatusBadRequest, Result: CR{"error": "phone must me not empty when email is empty"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=ivan2&phone=12345&password=short", Status: http.StatusBadRequest, Result: CR{"error": "password len must be >= 8"}},
+	})
+}
+
+func TestPatternParams(t *testing.T) {
+	api := NewUserApi()
+	password := "&password=secret12&password_confirm=secret12"
+
+	runCases(t, api, []Case{
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=petr_2&phone=%2B79001234567" + password, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "petr_2", "full_name": ""}}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=Petr&phone=100500" + password, Status: http.StatusBadRequest, Result: CR{"error": "login must match ^[a-z][a-z0-9_]*$"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=2petr&phone=100500" + password, Status: http.StatusBadRequest, Result: CR{"error": "login must match ^[a-z][a-z0-9_]*$"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&phone=100-500" + password, Status: http.StatusBadRequest, Result: CR{"error": "phone must match ^[+]?[0-9]{5,15}$"}},
+		// an optional field is matched only when sent
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&email=olga@mail.ru" + password, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "olga", "full_name": ""}}},
+	})
+}
+
+func TestFormatParams(t *testing.T) {
+	api := NewUserApi()
+	password := "&password=secret12&password_confirm=secret12"
+
+	runCases(t, api, []Case{
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=petr&email=petr@mail.ru&homepage=https://petr.dev/about&invite=6ba7b810-9dad-11d1-80b4-00c04fd430c8" + password, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "petr", "full_name": ""}}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&email=olga" + password, Status: http.StatusBadRequest, Result: CR{"error": "email must be a valid email address"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&email=Olga <olga@mail.ru>" + password, Status: http.StatusBadRequest, Result: CR{"error": "email must be a valid email address"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&phone=100500&homepage=olga.dev" + password, Status: http.StatusBadRequest, Result: CR{"error": "homepage must be a valid URL"}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=olga&phone=100500&invite=6ba7b810-9dad-11d1-80b4" + password, Status: http.StatusBadRequest, Result: CR{"error": "invite must be a valid UUID"}},
+	})
+}
+
+func TestAllErrors(t *testing.T) {
+	api := NewUserApi()
+	fe := func(field, rule, message string) CR {
+		return CR{"field": field, "rule": rule, "message": message}
+	}
+
+	runCases(t, api, []Case{
+		{Path: "/user/signup/check", Method: http.MethodPost, Query: "login=petr&email=petr@mail.ru&password=secret12", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "petr", "full_name": ""}}},
+		// one error of each field, in the field order
+		{Path: "/user/signup/check", Method: http.MethodPost, Query: "login=ab&email=ab&password=short&password_confirm=other", Status: http.StatusBadRequest, Result: CR{
+			"error": "login len must be >= 3",
+			"errors": []CR{
+				fe("login", "min", "login len must be >= 3"),
+				fe("email", "format", "email must be a valid email address"),
+				fe("password", "min", "password len must be >= 8"),
+				fe("password_confirm", "eqfield", "password_confirm must be equal to password"),
+			},
+		}},
+		{Path: "/user/signup/check", Method: http.MethodPost, Query: "role=admin", Status: http.StatusBadRequest, Result: CR{
+			"error": "login must me not empty",
+			"errors": []CR{
+				fe("login", "required", "login must me not empty"),
+				fe("reason", "required_if", "reason must me not empty when role is admin"),
+				fe("phone", "required_without", "phone must me not empty when email is empty"),
+				fe("password", "required", "password must me not empty"),
+			},
+		}},
+		// eqfield compares with a password that was sent only
+		{Path: "/user/signup/check", Method: http.MethodPost, Query: "login=petr&email=petr@mail.ru&password_confirm=secret12", Status: http.StatusBadRequest, Result: CR{
+			"error": "password must me not empty",
+			"errors": []CR{
+				fe("password", "required", "password must me not empty"),
+			},
+		}},
+		// a value that doesn't parse is reported once, its rules are skipped
+		{Path: "/user/signup/check", Method: http.MethodPost, Query: "login=petr&phone=100500&password=secret12&trial_from=2024-02-01&trial_to=tomorrow&invite=x", Status: http.StatusBadRequest, Result: CR{
+			"error": "trial_to must be formatted as 2006-01-02",
+			"errors": []CR{
+				fe("trial_to", "type", "trial_to must be formatted as 2006-01-02"),
+				fe("invite", "format", "invite must be a valid UUID"),
+			},
+		}},
+		// the default stays the first error
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=ab&email=ab&password=short", Status: http.StatusBadRequest, Result: CR{"error": "login len must be >= 3"}},
+	})
+}
+
+func TestNormalizedParams(t *testing.T) {
+	api := NewUserApi()
+
+	runCases(t, api, []Case{
+		{Path: "/user/profile", Query: "login=%20RVasily%20", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "rvasily", "full_name": "Vasily Romanov"}}},
+		// nothing left after trim is a missing param
+		{Path: "/user/profile", Query: "login=%20%20", Status: http.StatusBadRequest, Result: CR{"error": "login must me not empty"}},
+		{Path: "/user/rename", Method: http.MethodPost, Query: "login=+IVAN&full_name=++Ivan+%09Petrov++", Auth: true, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "ivan", "full_name": "Ivan Petrov"}}},
+		{Path: "/user/rename", Method: http.MethodPost, Query: "login=ivan&full_name=+++", Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "full_name must me not empty"}},
+		// the rules see the trimmed value
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=+petr+&email=petr@mail.ru&password=secret12", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "petr", "full_name": ""}}},
+		{Path: "/user/signup", Method: http.MethodPost, Query: "login=+ab+&email=ab@mail.ru&password=secret12", Status: http.StatusBadRequest, Result: CR{"error": "login len must be >= 3"}},
+	})
+}
+
+func TestJSONBody(t *testing.T) {
+	api := NewUserApi()
+	signup := func(body string, status int, result interface{}) Case {
+		return Case{Path: "/user/signup", JSON: body, Status: status, Result: result}
+	}
+
+	runCases(t, api, []Case{
+		signup(`{"login": "petr", "email": "petr@mail.ru", "password": "secret12", "passwordConfirm": "secret12", "trial_to": null}`, http.StatusOK, CR{"error": "", "response": CR{"login": "petr", "full_name": ""}}),
+		// the same rules as for the form
+		signup(`{"login": "ab", "email": "ab@mail.ru", "password": "secret12"}`, http.StatusBadRequest, CR{"error": "login len must be >= 3"}),
+		signup(`{"login": "olga", "email": "olga@mail.ru", "password": "secret12", "passwordConfirm": "secret13"}`, http.StatusBadRequest, CR{"error": "password_confirm must be equal to password"}),
+		signup(`{"login": "olga", "email": "olga@mail.ru", "password": "secret12", "trial_from": "2024-02-01", "trial_to": "2024-01-01"}`, http.StatusBadRequest, CR{"error": "trial_to must be greater than trial_from"}),
+		// the json tag names the key, not the param name
+		signup(`{"login": "olga", "password_confirm": "secret12"}`, http.StatusBadRequest, CR{"error": "unknown field password_confirm"}),
+		signup(`{"login": "olga", "nick": "o"}`, http.StatusBadRequest, CR{"error": "unknown field nick"}),
+		signup(`{"login": 42}`, http.StatusBadRequest, CR{"error": "login must be a string"}),
+		signup(`{"login": "olga"`, http.StatusBadRequest, CR{"error": "invalid JSON body: unexpected EOF"}),
+		signup(`{"login": "olga"} {}`, http.StatusBadRequest, CR{"error": "invalid JSON body: unexpected data after the object"}),
+		signup(`["olga"]`, http.StatusBadRequest, CR{"error": "JSON body must be an object"}),
+		signup(`{"login": "`+strings.Repeat("o", 1<<20)+`"}`, http.StatusRequestEntityTooLarge, CR{"error": "request body is too large"}),
+		{Path: "/user/search", JSON: `{"filter": {"status": "active", "age": 30, "login": "rv"}, "page": {"limit": 1}}`, Status: http.StatusOK, Result: CR{
+			"error": "",
+			"response": CR{"filter": "status=active login=rv age=30", "limit": 1, "users": []interface{}{
+				CR{"login": "rvasily", "full_name": "Vasily Romanov"},
+			}},
+		}},
+		{Path: "/user/search", JSON: `{"filter": {"age": "30"}}`, Status: http.StatusBadRequest, Result: CR{"error": "filter.age must be a number"}},
+		{Path: "/user/search", JSON: `{"filter": "active"}`, Status: http.StatusBadRequest, Result: CR{"error": "filter must be an object"}},
+		{Path: "/user/search", JSON: `{"filter": {"status": "deleted"}}`, Status: http.StatusBadRequest, Result: CR{"error": "filter.status must be one of [active, blocked]"}},
+		{Path: "/user/find", JSON: `{"login": ["ivan", "rvasily", "nobody"]}`, Status: http.StatusOK, Result: CR{
+			"error": "",
+			"response": CR{"users": []CR{
+				{"login": "ivan", "full_name": "Ivan Ivanov"},
+				{"login": "rvasily", "full_name": "Vasily Romanov"},
+			}, "tenant": ""},
+		}},
+		{Path: "/user/find", JSON: `{"login": "ivan"}`, Status: http.StatusBadRequest, Result: CR{"error": "login must be an array"}},
+		{Path: "/user/find", JSON: `{"login": ["ivan", 1]}`, Status: http.StatusBadRequest, Result: CR{"error": "login must be an array of strings"}},
+		{Path: "/user/rate", JSON: `{"login": "ivan", "score": 4.5, "weight": 70000}`, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "weight is out of uint16 range"}},
+		{Path: "/user/rate", JSON: `{"login": "ivan", "score": 4.5, "public": "yes"}`, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "public must be a boolean"}},
+		{Path: "/user/signup/check", JSON: `{"login": "ab", "email": "ab@mail.ru", "password": "short"}`, Status: http.StatusBadRequest, Result: CR{
+			"error": "login len must be >= 3",
+			"errors": []CR{
+				{"field": "login", "rule": "min", "message": "login len must be >= 3"},
+				{"field": "password", "rule": "min", "message": "password len must be >= 8"},
+			},
+		}},
+	})
+}
+
+func TestFileParams(t *testing.T) {
+	api := NewUserApi()
+	png := File{Key: "avatar", Name: "me.png", ContentType: "image/png", Content: "\x89PNG..."}
+	csv := func(name, content string) File {
+		return File{Key: "file", Name: name, ContentType: "text/csv", Content: content}
+	}
+
+	runCases(t, api, []Case{
+		{Path: "/user/avatar", Query: "login=rvasily", Files: []File{png}, Auth: true, Status: http.StatusOK, Result: CR{
+			"error":    "",
+			"response": CR{"login": "rvasily", "size": 7, "content_type": "image/png"},
+		}},
+		{Path: "/user/avatar", Query: "login=rvasily", Files: []File{}, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "avatar must me not empty"}},
+		{Path: "/user/avatar", Query: "login=rvasily", Files: []File{{Key: "avatar", Name: "me.gif", ContentType: "image/gif", Content: "GIF89a"}}, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "avatar content type must be one of [image/png, image/jpeg]"}},
+		// over the memory limit the file goes to a temporary file, over maxsize it is refused
+		{Path: "/user/avatar", Query: "login=rvasily", Files: []File{{Key: "avatar", Name: "big.png", ContentType: "image/png", Content: strings.Repeat("x", 1<<20+1)}}, Auth: true, Status: http.StatusRequestEntityTooLarge, Result: CR{"error": "avatar must be at most 1MB"}},
+		// past maxsize and the memory limit the body is not read any further
+		{Path: "/user/avatar", Query: "login=rvasily", Files: []File{{Key: "avatar", Name: "huge.png", ContentType: "image/png", Content: strings.Repeat("x", 2<<20+1)}}, Auth: true, Status: http.StatusRequestEntityTooLarge, Result: CR{"error": "request body is too large"}},
+		{Path: "/user/avatar", Query: "login=nobody", Files: []File{png}, Auth: true, Status: http.StatusNotFound, Result: CR{"error": "user not exist"}},
+		{Path: "/user/import", Files: []File{csv("a.csv", "petr,Petr Petrov\nolga,Olga Ivanova\n"), csv("b.csv", "ivan,Ivan Ivanov\n")}, Auth: true, Status: http.StatusOK, Result: CR{
+			"error":    "",
+			"response": CR{"imported": 2, "skipped": 1},
+		}},
+		{Path: "/user/profile", Query: "login=olga", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "olga", "full_name": "Olga Ivanova"}}},
+		{Path: "/user/import", Files: []File{csv("a.csv", "a,b"), csv("b.csv", "c,d"), csv("c.csv", "e,f"), csv("d.csv", "g,h")}, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "file must have at most 3 items"}},
+		{Path: "/user/import", Files: []File{{Key: "file", Name: "a.txt", ContentType: "text/plain", Content: "a,b"}}, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "file item content type must be one of [text/csv]"}},
+		{Path: "/user/import", Files: []File{csv("a.csv", strings.Repeat("x", 64<<10+1))}, Auth: true, Status: http.StatusRequestEntityTooLarge, Result: CR{"error": "file item must be at most 64KB"}},
+		{Path: "/user/import", Files: []File{csv("a.csv", "anna\n")}, Auth: true, Status: http.StatusBadRequest, Result: CR{"error": "a.csv: record on line 1: wrong number of fields"}},
+		// the text params of a multipart body are bound like the form ones
+		{Path: "/user/profile", Query: "login=rmaria", Files: []File{}, Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "rmaria", "full_name": "Maria Romanova"}}},
+	})
+}
+
+func TestPathParams(t *testing.T) {
+	rate := func(path, query string, status int, result interface{}) Case {
+		return Case{Path: path, Method: http.MethodPost, Auth: true, Query: query, Status: status, Result: result}
+	}
+
+	runCases(t, NewUserApi(), []Case{
+		{Path: "/user/rvasily/profile", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "rvasily", "full_name": "Vasily Romanov"}}},
+		// normalized like the query param
+		{Path: "/user/%20RVasily%20/profile", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "rvasily", "full_name": "Vasily Romanov"}}},
+		// the path wins over the query
+		{Path: "/user/ivan/profile", Query: "login=rvasily", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "ivan", "full_name": "Ivan Ivanov"}}},
+		// an escaped slash is a part of the segment
+		{Path: "/user/a%2Fb/profile", Status: http.StatusNotFound, Result: CR{"error": "user not exist"}},
+		{Path: "/user//profile", Status: http.StatusNotFound, Result: CR{"error": "unknown method"}},
+		{Path: "/user/ivan/rvasily/profile", Status: http.StatusNotFound, Result: CR{"error": "unknown method"}},
+		// the literal url is still served
+		{Path: "/user/profile", Query: "login=ivan", Status: http.StatusOK, Result: CR{"error": "", "response": CR{"login": "ivan", "full_name": "Ivan Ivanov"}}},
+		rate("/user/ivan/rate/4.5", "weight=10", http.StatusOK, CR{
+			"error":    "",
+			"response": CR{"login": "ivan", "score": 4.5, "weight": 10, "public": false, "nonce": 0},
+		}),
+		rate("/user/ivan/rate/five", "", http.StatusBadRequest, CR{"error": "score must be float32"}),
+		rate("/user/ivan/rate/9", "", http.StatusBadRequest, CR{"error": "score must be <= 5"}),
+		rate("/user/olga/rate/4", "", http.StatusNotFound, CR{"error": "user not exist"}),
+		{Path: "/user/ivan/rate/4", Auth: true, Status: http.StatusNotAcceptable, Result: CR{"error": "bad method"}},
+	})
+}
@@ -0,0 +1,53 @@
+package example
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// discard is a ResponseWriter that keeps nothing, so that the benchmarks
+// count the allocations of the handlers rather than of a recorder.
+type discard struct {
+	header http.Header
+}
+
+func (d *discard) Header() http.Header         { return d.header }
+func (d *discard) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discard) WriteHeader(int)             {}
+
+// BenchmarkHandlers binds, validates and answers a valid request of several
+// endpoints; the form is parsed once, before the timer starts.
+func BenchmarkHandlers(b *testing.B) {
+	api := NewUserApi()
+
+	for _, c := range []struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+	}{
+		{"Profile", api.handleProfile, "login=rvasily"},
+		{"Rate", api.handleRate, "login=rvasily&score=4.5&weight=10&public=true&nonce=42"},
+		{"Find", api.handleFind, "login=rvasily&login=rmaria&age=20,30"},
+		{"Search", api.handleSearch, "filter.status=active&filter[age]=30&page.limit=2&sort=login,desc"},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+c.query, nil)
+			r.ParseForm()
+
+			rec := httptest.NewRecorder()
+			if c.handler(rec, r); rec.Code != http.StatusOK {
+				b.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+			}
+
+			w := &discard{header: http.Header{}}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				c.handler(w, r)
+			}
+		})
+	}
+}
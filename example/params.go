@@ -0,0 +1,139 @@
+package example
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ngoryachev/go_api_gen/example/shared"
+)
+
+// ProfileParams takes the login as typed, " RVasily " finds rvasily.
+type ProfileParams struct {
+	Login shared.Login `apivalidator:"required,trim,lower"`
+}
+
+type RenameParams struct {
+	Login shared.Login `apivalidator:"required,min=3,trim,lower"`
+	Name  string       `apivalidator:"paramname=full_name,required,trim,collapse"`
+}
+
+// UpdateParams changes only the fields that were sent.
+type UpdateParams struct {
+	Login shared.Login `apivalidator:"required"`
+	Name  *string      `apivalidator:"paramname=full_name,min=1"`
+	Age   *int         `apivalidator:"min=0,max=150"`
+}
+
+// FindParams takes ?login=a&login=b and ?age=20,30.
+type FindParams struct {
+	Logins []shared.Login `apivalidator:"paramname=login,required,maxitems=3,min=3"`
+	Ages   []int          `apivalidator:"paramname=age,split=,,min=0,max=150"`
+}
+
+type RateParams struct {
+	Login  shared.Login `apivalidator:"required"`
+	Score  float32      `apivalidator:"required,min=0.5,max=5"`
+	Weight uint16       `apivalidator:"max=1000,default=1"`
+	Public bool         `apivalidator:"paramname=public"`
+	Nonce  uint64       `apivalidator:"paramname=nonce"`
+}
+
+// ActivityParams takes ?from=2024-01-31&window=48h, its Validate checks the
+// range as a whole. Since has no layout and is written in RFC 3339.
+type ActivityParams struct {
+	Login  shared.Login  `apivalidator:"required"`
+	From   time.Time     `apivalidator:"required,layout=DateOnly,min=2020-01-01"`
+	To     *time.Time    `apivalidator:"layout=DateOnly"`
+	Window time.Duration `apivalidator:"default=24h,min=1m,max=168h"`
+	Since  *time.Time    `apivalidator:"paramname=since,min=2020-01-01T00:00:00Z"`
+}
+
+func (in ActivityParams) Validate() error {
+	if in.To != nil && in.To.Before(in.From) {
+		return fmt.Errorf("to must be after from")
+	}
+
+	return nil
+}
+
+// Role takes its enum from the consts: user, moderator or admin.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// InviteParams binds shared.Email and net.IP with their UnmarshalText.
+type InviteParams struct {
+	Login   shared.Login   `apivalidator:"required"`
+	Email   shared.Email   `apivalidator:"required"`
+	Cc      []shared.Email `apivalidator:"split=,,maxitems=2"`
+	ReplyTo *shared.Email  `apivalidator:"paramname=reply_to"`
+	Origin  net.IP         `apivalidator:"paramname=origin"`
+	Role    Role           `apivalidator:"default=user"`
+}
+
+// Validate keeps the status of the ApiError it returns.
+func (in *InviteParams) Validate(ctx context.Context) error {
+	for _, cc := range in.Cc {
+		if cc == in.Email {
+			return ApiError{http.StatusUnprocessableEntity, fmt.Errorf("cc must not repeat email")}
+		}
+	}
+
+	return nil
+}
+
+// SearchFilter is nested into SearchParams, bound from filter.status or
+// filter[status].
+type SearchFilter struct {
+	Status shared.Status `apivalidator:"paramname=status"`
+	Age    *int          `apivalidator:"min=0,max=150"`
+	Login  shared.Login  `apivalidator:"paramname=login,min=2"`
+}
+
+type SearchParams struct {
+	Filter SearchFilter
+	Page   *shared.Pagination `apivalidator:"paramname=page"`
+	// quoted, the values hold commas
+	Sort string `apivalidator:"enum='login,asc'|'login,desc',default='login,asc'"`
+}
+
+type DeleteParams struct {
+	Login shared.Login `apivalidator:"required"`
+}
+
+// SignupParams ties its fields together: an admin gives a reason, a user
+// gives an email or a phone, the password is typed twice and the trial ends
+// after it starts. The login and the phone are matched against patterns,
+// the email, the homepage and the invite code are checked by their format.
+type SignupParams struct {
+	Login           shared.Login `apivalidator:"required,trim,min=3,pattern=^[a-z][a-z0-9_]*$"`
+	Role            Role         `apivalidator:"default=user"`
+	Reason          string       `apivalidator:"required_if=Role:admin"`
+	Email           string       `apivalidator:"paramname=email,format=email"`
+	Phone           string       `apivalidator:"required_without=Email,pattern='^[+]?[0-9]{5,15}$'"`
+	Password        string       `apivalidator:"required,min=8"`
+	PasswordConfirm string       `json:"passwordConfirm" apivalidator:"paramname=password_confirm,eqfield=Password"`
+	TrialFrom       *time.Time   `apivalidator:"paramname=trial_from,layout=DateOnly"`
+	TrialTo         *time.Time   `apivalidator:"paramname=trial_to,layout=DateOnly,gtfield=TrialFrom"`
+	Homepage        *string      `apivalidator:"paramname=homepage,format=url"`
+	Invite          string       `apivalidator:"paramname=invite,format=uuid"`
+}
+
+// AvatarParams takes a picture of up to 1MB in a multipart body.
+type AvatarParams struct {
+	Login  shared.Login          `apivalidator:"required"`
+	Avatar *multipart.FileHeader `apivalidator:"required,maxsize=1MB,contenttype=image/png|image/jpeg"`
+}
+
+// ImportParams takes up to three CSV files of login,full_name lines.
+type ImportParams struct {
+	Files []*multipart.FileHeader `apivalidator:"paramname=file,required,maxitems=3,maxsize=64KB,contenttype=text/csv"`
+}
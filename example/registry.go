@@ -0,0 +1,34 @@
+package example
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Registry is a generic API, its handlers are generated once for any T.
+type Registry[T any] struct {
+	items map[string]T
+	mu    sync.RWMutex
+}
+
+func NewRegistry[T any](items map[string]T) *Registry[T] {
+	return &Registry[T]{items: items}
+}
+
+type RegistryParams struct {
+	Key string `apivalidator:"required"`
+}
+
+// apigen:api {"url": "/registry/get"}
+func (reg *Registry[T]) Get(in RegistryParams) (T, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	item, ok := reg.items[in.Key]
+	if !ok {
+		return item, ApiError{http.StatusNotFound, fmt.Errorf("%s not found", in.Key)}
+	}
+
+	return item, nil
+}
@@ -0,0 +1,55 @@
+// Package shared holds parameter types reused by several APIs.
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Login is a user login as it comes from the request.
+type Login string
+
+// Status is the state of an account, a filter takes one of the consts below.
+type Status string
+
+const (
+	StatusActive  Status = "active"
+	StatusBlocked Status = "blocked"
+)
+
+// Email is an address normalized to lower case, it binds through UnmarshalText.
+type Email struct {
+	User, Domain string
+}
+
+func (e *Email) UnmarshalText(text []byte) error {
+	user, domain, ok := strings.Cut(strings.ToLower(string(text)), "@")
+	if !ok || user == "" || domain == "" {
+		return fmt.Errorf("%q is not an email", text)
+	}
+
+	e.User, e.Domain = user, domain
+
+	return nil
+}
+
+func (e Email) String() string {
+	return e.User + "@" + e.Domain
+}
+
+// Pagination is embedded into the parameters of list endpoints.
+type Pagination struct {
+	Limit  int `apivalidator:"min=1,max=100,default=10"`
+	Offset int `apivalidator:"min=0,max=1000,default=0"`
+}
+
+// TenantScope is embedded by pointer into parameters of tenant-aware endpoints.
+type TenantScope struct {
+	Tenant string `apivalidator:"default=main"`
+}
+
+type ListParams struct {
+	Prefix Login `apivalidator:"paramname=prefix"`
+	Pagination
+	*TenantScope
+}
@@ -0,0 +1,104 @@
+package example
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/ngoryachev/go_api_gen/example/shared"
+)
+
+// Avatar is what is kept of an uploaded picture.
+type Avatar struct {
+	Login       string `json:"login"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// apigen:api {"url": "/user/avatar", "auth": true, "method": "POST", "multipart_memory": 1048576}
+func (srv *UserApi) UploadAvatar(ctx context.Context, in AvatarParams) (*Avatar, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if _, exist := srv.users[in.Login]; !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	avatar := Avatar{
+		Login:       string(in.Login),
+		Size:        in.Avatar.Size,
+		ContentType: in.Avatar.Header.Get("Content-Type"),
+	}
+	srv.avatars[in.Login] = avatar
+
+	return &avatar, nil
+}
+
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// Import adds the users of the CSV files, the existing logins are skipped.
+// apigen:api {"url": "/user/import", "auth": true, "method": "POST"}
+func (srv *UserApi) Import(ctx context.Context, in ImportParams) (*ImportResult, error) {
+	var users []*User
+
+	for _, file := range in.Files {
+		records, err := readCSV(file)
+		if err != nil {
+			return nil, ApiError{http.StatusBadRequest, fmt.Errorf("%s: %v", file.Filename, err)}
+		}
+
+		for _, record := range records {
+			users = append(users, &User{Login: record[0], FullName: record[1]})
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	result := &ImportResult{}
+
+	for _, user := range users {
+		if _, exist := srv.users[shared.Login(user.Login)]; exist {
+			result.Skipped++
+
+			continue
+		}
+
+		srv.users[shared.Login(user.Login)] = user
+		result.Imported++
+	}
+
+	return result, nil
+}
+
+func readCSV(file *multipart.FileHeader) ([][]string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+
+	var records [][]string
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+}
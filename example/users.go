@@ -0,0 +1,372 @@
+package example
+
+//go:generate go run ../handlers_gen .
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngoryachev/go_api_gen/example/shared"
+)
+
+// ApiError is the error type generated handlers take the HTTP status from.
+type ApiError struct {
+	HTTPStatus int
+	Err        error
+}
+
+func (ae ApiError) Error() string {
+	return ae.Err.Error()
+}
+
+type User struct {
+	Login    string `json:"login"`
+	FullName string `json:"full_name"`
+	Age      int    `json:"age,omitempty"`
+}
+
+type UserList struct {
+	Tenant string  `json:"tenant"`
+	Users  []*User `json:"users"`
+}
+
+type UserApi struct {
+	users   map[shared.Login]*User
+	avatars map[shared.Login]Avatar
+	mu      *sync.RWMutex
+}
+
+func NewUserApi() *UserApi {
+	return &UserApi{
+		users: map[shared.Login]*User{
+			"rvasily": {Login: "rvasily", FullName: "Vasily Romanov"},
+			"rmaria":  {Login: "rmaria", FullName: "Maria Romanova"},
+			"ivan":    {Login: "ivan", FullName: "Ivan Ivanov"},
+		},
+		avatars: map[shared.Login]Avatar{},
+		mu:      &sync.RWMutex{},
+	}
+}
+
+// apigen:api {"url": "/user/profile", "auth": false}
+func (srv *UserApi) Profile(ctx context.Context, in ProfileParams) (*User, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	user, exist := srv.users[in.Login]
+	if !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	return user, nil
+}
+
+// UserProfile is Profile at a REST url, the login comes from the path:
+// /user/rvasily/profile.
+//
+// apigen:api {"url": "/user/{login}/profile"}
+func (srv *UserApi) UserProfile(ctx context.Context, in ProfileParams) (*User, error) {
+	return srv.Profile(ctx, in)
+}
+
+// apigen:api {"url": "/user/rename", "auth": true, "method": "POST"}
+func (srv *UserApi) Rename(ctx context.Context, in *RenameParams) (*User, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	user, exist := srv.users[in.Login]
+	if !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	user.FullName = in.Name
+
+	return user, nil
+}
+
+// apigen:api {"url": "/user/update", "auth": true, "method": "POST"}
+func (srv *UserApi) Update(ctx context.Context, in UpdateParams) (*User, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	user, exist := srv.users[in.Login]
+	if !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	if in.Name != nil {
+		user.FullName = *in.Name
+	}
+
+	if in.Age != nil {
+		user.Age = *in.Age
+	}
+
+	return user, nil
+}
+
+// apigen:api {"url": "/user/find"}
+func (srv *UserApi) Find(ctx context.Context, in FindParams) (*UserList, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	list := &UserList{Users: []*User{}}
+
+	for _, login := range in.Logins {
+		user, exist := srv.users[login]
+		if !exist {
+			continue
+		}
+
+		matched := len(in.Ages) == 0
+		for _, age := range in.Ages {
+			matched = matched || user.Age == age
+		}
+
+		if matched {
+			list.Users = append(list.Users, user)
+		}
+	}
+
+	return list, nil
+}
+
+type Rating struct {
+	Login  string  `json:"login"`
+	Score  float32 `json:"score"`
+	Weight uint16  `json:"weight"`
+	Public bool    `json:"public"`
+	Nonce  uint64  `json:"nonce"`
+}
+
+// apigen:api {"url": "/user/rate", "auth": true, "method": "POST"}
+func (srv *UserApi) Rate(ctx context.Context, in RateParams) (*Rating, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if _, exist := srv.users[in.Login]; !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	return &Rating{
+		Login:  string(in.Login),
+		Score:  in.Score,
+		Weight: in.Weight,
+		Public: in.Public,
+		Nonce:  in.Nonce,
+	}, nil
+}
+
+// RateUser is Rate with the login and the score in the path, which are
+// converted and validated like the query ones: /user/rvasily/rate/4.5.
+//
+// apigen:api {"url": "/user/{login}/rate/{score}", "auth": true, "method": "POST"}
+func (srv *UserApi) RateUser(ctx context.Context, in RateParams) (*Rating, error) {
+	return srv.Rate(ctx, in)
+}
+
+// apigen:api {"url": "/user/list"}
+func (srv *UserApi) List(ctx context.Context, in shared.ListParams) (*UserList, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	list := &UserList{Tenant: in.Tenant, Users: []*User{}}
+
+	for login, user := range srv.users {
+		if strings.HasPrefix(string(login), string(in.Prefix)) {
+			list.Users = append(list.Users, user)
+		}
+	}
+
+	sort.Slice(list.Users, func(i, j int) bool {
+		return list.Users[i].Login < list.Users[j].Login
+	})
+
+	if in.Offset > len(list.Users) {
+		in.Offset = len(list.Users)
+	}
+
+	list.Users = list.Users[in.Offset:]
+
+	if len(list.Users) > in.Limit {
+		list.Users = list.Users[:in.Limit]
+	}
+
+	return list, nil
+}
+
+type Activity struct {
+	Login  string `json:"login"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Window string `json:"window"`
+	Since  string `json:"since,omitempty"`
+}
+
+// apigen:api {"url": "/user/activity"}
+func (srv *UserApi) Activity(ctx context.Context, in ActivityParams) (*Activity, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if _, exist := srv.users[in.Login]; !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	to := in.From.Add(in.Window)
+	if in.To != nil {
+		to = *in.To
+	}
+
+	activity := &Activity{
+		Login:  string(in.Login),
+		From:   in.From.Format(time.RFC3339),
+		To:     to.Format(time.RFC3339),
+		Window: in.Window.String(),
+	}
+
+	if in.Since != nil {
+		activity.Since = in.Since.Format(time.RFC3339)
+	}
+
+	return activity, nil
+}
+
+type Invite struct {
+	Login   string   `json:"login"`
+	Email   string   `json:"email"`
+	Cc      []string `json:"cc"`
+	ReplyTo string   `json:"reply_to"`
+	Origin  string   `json:"origin"`
+	Role    string   `json:"role"`
+}
+
+// Invite sends an invitation to the email.
+//
+// apigen:api {
+// "url": "/user/invite",
+// "auth": true,
+// "method": "POST"
+// }
+func (srv *UserApi) Invite(ctx context.Context, in InviteParams) (*Invite, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if _, exist := srv.users[in.Login]; !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	invite := &Invite{Login: string(in.Login), Email: in.Email.String(), Cc: []string{}, ReplyTo: in.Email.String(), Role: string(in.Role)}
+
+	for _, cc := range in.Cc {
+		invite.Cc = append(invite.Cc, cc.String())
+	}
+
+	if in.ReplyTo != nil {
+		invite.ReplyTo = in.ReplyTo.String()
+	}
+
+	if in.Origin != nil {
+		invite.Origin = in.Origin.String()
+	}
+
+	return invite, nil
+}
+
+type SearchResult struct {
+	Filter string  `json:"filter"`
+	Limit  int     `json:"limit"`
+	Users  []*User `json:"users"`
+}
+
+// apigen:api {"url": "/user/search"}
+func (srv *UserApi) Search(ctx context.Context, in SearchParams) (*SearchResult, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	filter := fmt.Sprintf("status=%s login=%s", in.Filter.Status, in.Filter.Login)
+	if in.Filter.Age != nil {
+		filter += fmt.Sprintf(" age=%d", *in.Filter.Age)
+	}
+
+	result := &SearchResult{Filter: filter, Limit: in.Page.Limit, Users: []*User{}}
+
+	for login, user := range srv.users {
+		if strings.HasPrefix(string(login), string(in.Filter.Login)) {
+			result.Users = append(result.Users, user)
+		}
+	}
+
+	sort.Slice(result.Users, func(i, j int) bool {
+		if in.Sort == "login,desc" {
+			return result.Users[i].Login > result.Users[j].Login
+		}
+
+		return result.Users[i].Login < result.Users[j].Login
+	})
+
+	if len(result.Users) > in.Page.Limit {
+		result.Users = result.Users[:in.Page.Limit]
+	}
+
+	return result, nil
+}
+
+// apigen:api {"url": "/user/delete", "auth": true, "method": "POST"}
+func (srv *UserApi) Delete(ctx context.Context, in DeleteParams) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if _, exist := srv.users[in.Login]; !exist {
+		return ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+
+	delete(srv.users, in.Login)
+
+	return nil
+}
+
+// apigen:api {"url": "/user/signup", "method": "POST"}
+func (srv *UserApi) Signup(ctx context.Context, in SignupParams) (*User, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if _, exist := srv.users[in.Login]; exist {
+		return nil, ApiError{http.StatusConflict, fmt.Errorf("user %s exist", in.Login)}
+	}
+
+	user := &User{Login: string(in.Login)}
+	srv.users[in.Login] = user
+
+	return user, nil
+}
+
+// CheckSignup answers with all the invalid fields of a signup form at once,
+// so that a client can highlight them together.
+// apigen:api {"url": "/user/signup/check", "method": "POST", "all_errors": true}
+func (srv *UserApi) CheckSignup(ctx context.Context, in SignupParams) (*User, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if _, exist := srv.users[in.Login]; exist {
+		return nil, ApiError{http.StatusConflict, fmt.Errorf("user %s exist", in.Login)}
+	}
+
+	return &User{Login: string(in.Login)}, nil
+}
+
+type Stats struct {
+	Users int `json:"users"`
+}
+
+// apigen:api {"url": "/user/stats"}
+func (srv UserApi) Stats() (Stats, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return Stats{Users: len(srv.users)}, nil
+}
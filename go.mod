@@ -1,5 +1,3 @@
 module github.com/ngoryachev/go_api_gen
 
 go 1.20
-
-require github.com/asaskevich/govalidator v0.0.0-20230301143203-a9d515a09cc2
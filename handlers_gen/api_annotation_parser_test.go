@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApiGenArgsParse(t *testing.T) {
+	for _, c := range []struct {
+		doc      string
+		expected ApiGenArgs
+	}{
+		{`apigen:api {"url": "/user/profile", "auth": false}`, ApiGenArgs{Url: "/user/profile"}},
+		// TrimLeft used to eat the leading "a" of the url key
+		{`apigen:api {"auth": true, "url": "/a", "method": "POST"}`, ApiGenArgs{Url: "/a", Auth: true, Method: "POST"}},
+		{"Create makes a user.\nIt needs auth.\n\napigen:api {\n  \"url\": \"/user/create\",\n  \"method\": \"PUT\"\n}\n", ApiGenArgs{Url: "/user/create", Method: "PUT"}},
+		{`apigen:api {"url": "/user/signup/check", "method": "POST", "all_errors": true}`, ApiGenArgs{Url: "/user/signup/check", Method: "POST", AllErrors: true}},
+		{`apigen:api {"url": "/user/avatar", "method": "POST", "multipart_memory": 1048576}`, ApiGenArgs{Url: "/user/avatar", Method: "POST", MultipartMemory: 1 << 20}},
+		{`apigen:api {"url": "/user/avatar", "method": "POST", "max_body": 4194304}`, ApiGenArgs{Url: "/user/avatar", Method: "POST", MaxBody: 4 << 20}},
+		{`apigen:api {"url": "/user/{login}/rate/{score}", "method": "POST"}`, ApiGenArgs{Url: "/user/{login}/rate/{score}", Method: "POST"}},
+	} {
+		args := ApiGenArgs{}
+		if err := args.Parse(c.doc); err != nil {
+			t.Errorf("%q: %v", c.doc, err)
+
+			continue
+		}
+
+		if args != c.expected {
+			t.Errorf("%q: expected %+v, got %+v", c.doc, c.expected, args)
+		}
+	}
+
+	for _, c := range []struct {
+		doc, err string
+	}{
+		{`apigen:api {"url": "/a", "metod": "POST"}`, `unknown key "metod"`},
+		{`apigen:api {"url": "/a", "method": "GRAB"}`, `method "GRAB" is not one of GET, HEAD, POST`},
+		{`apigen:api {"url": "/a", "method": "post"}`, `method "post" is not one of`},
+		{`apigen:api {"url": "user/profile"}`, `url "user/profile" must start with /`},
+		{`apigen:api {"auth": true}`, `url "" must start with /`},
+		{`apigen:api {"url": "/a",}`, `invalid character '}'`},
+		{`apigen:api {"url": "/a", "auth": "yes"}`, `cannot unmarshal string`},
+		{`apigen:api {"url": "/a", "multipart_memory": -1}`, `multipart_memory -1 must not be negative`},
+		{`apigen:api {"url": "/a", "max_body": -1}`, `max_body -1 must not be negative`},
+		{`apigen:api {"url": "/user/{login}x"}`, `segment {login}x must be a whole {name} placeholder`},
+		{`apigen:api {"url": "/user/{}"}`, `segment {} must be a whole {name} placeholder`},
+		{`apigen:api {"url": "/user/{filter.status}"}`, `segment {filter.status} must be a whole {name} placeholder`},
+		{`apigen:api {"url": "/user/{login}/{login}"}`, `placeholder {login} is repeated`},
+		{`apigen:api {"url": "/a"} {"method": "POST"}`, `unexpected "{\"method\": \"POST\"}" after the JSON object`},
+		{"apigen:api {\"url\": \"/a\"}\nIt needs auth.\n", `unexpected "It needs auth." after the JSON object`},
+		{`apigen:api {"url": "/a\"b"}`, `url "/a\"b": '"' is not allowed in a path`},
+		{`apigen:api {"url": "/a\\b"}`, `url "/a\\b": '\\' is not allowed in a path`},
+		{`apigen:api {"url": "/a?b=1"}`, `'?' is not allowed in a path`},
+		{`apigen:api {"url": "/a b"}`, `' ' is not allowed in a path`},
+		{`apigen:api`, `no JSON object`},
+		{`just a comment`, `no apigen:api annotation`},
+	} {
+		args := ApiGenArgs{}
+		if err := args.Parse(c.doc); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: error %q expected, got %v", c.doc, c.err, err)
+		}
+	}
+}
+
+func TestApiGenArgsPlaceholders(t *testing.T) {
+	for url, expected := range map[string]string{
+		"/user/profile":               "",
+		"/user/{login}/profile":       "login",
+		"/user/{login}/rate/{score}":  "login score",
+		"/{full_name}/{user-id}/{id}": "full_name user-id id",
+	} {
+		args := ApiGenArgs{Url: url}
+		if got := strings.Join(args.Placeholders(), " "); got != expected {
+			t.Errorf("%s: expected %q, got %q", url, expected, got)
+		}
+	}
+}
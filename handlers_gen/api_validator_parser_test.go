@@ -2,6 +2,7 @@ package main
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -113,3 +114,117 @@ func TestApiValidatorDefault(t *testing.T) {
 		}
 	})
 }
+
+//Tags []string `apivalidator:"split=,,minitems=1,maxitems=5"`
+func TestApiValidatorItems(t *testing.T) {
+	ApiValidatorGeneric(t, `apivalidator:"split=,,minitems=1,maxitems=5"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.Split == ",",
+			fv.IsMinItems == true,
+			fv.MinItems == 1,
+			fv.IsMaxItems == true,
+			fv.MaxItems == 5,
+		}
+	})
+	ApiValidatorGeneric(t, `apivalidator:"minitems=2,split=;"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.Split == ";",
+			fv.MinItems == 2,
+			fv.IsMaxItems == false,
+		}
+	})
+	ApiValidatorGeneric(t, `apivalidator:"split=,"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.Split == ",",
+		}
+	})
+}
+
+//Score float32 `apivalidator:"min=0.5,max=5"`
+func TestApiValidatorFloatMinMax(t *testing.T) {
+	ApiValidatorGeneric(t, `apivalidator:"min=0.5,max=5"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.Min == 0.5,
+			fv.MinLit == "0.5",
+			fv.Max == 5,
+			fv.MaxLit == "5",
+		}
+	})
+}
+
+//Login shared.Login `apivalidator:"required,trim,lower"`
+func TestApiValidatorNormalize(t *testing.T) {
+	ApiValidatorGeneric(t, `apivalidator:"upper,required,trim,collapse"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.Required == true,
+			fv.Trim == true,
+			fv.Collapse == true,
+			fv.Upper == true,
+			fv.Lower == false,
+			reflect.DeepEqual(fv.Normalize(), []string{"trim", "collapse", "upper"}),
+		}
+	})
+	ApiValidatorGeneric(t, `apivalidator:"min=3"`, func(fv *FieldValidator) []bool {
+		return []bool{fv.Normalize() == nil}
+	})
+}
+
+//Avatar *multipart.FileHeader `apivalidator:"maxsize=2MB,contenttype=image/png|Image/*"`
+func TestApiValidatorFile(t *testing.T) {
+	ApiValidatorGeneric(t, `apivalidator:"maxsize=2MB,contenttype=image/png|Image/*"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.MaxSize == 2<<20,
+			fv.MaxSizeLit == "2MB",
+			reflect.DeepEqual(fv.ContentTypes, []string{"image/png", "image/*"}),
+		}
+	})
+	ApiValidatorGeneric(t, `apivalidator:"maxsize=512"`, func(fv *FieldValidator) []bool {
+		return []bool{fv.MaxSize == 512}
+	})
+	ApiValidatorGeneric(t, `apivalidator:"maxsize=64KB"`, func(fv *FieldValidator) []bool {
+		return []bool{fv.MaxSize == 64<<10}
+	})
+}
+
+//Sep string `json:"sep" apivalidator:"enum=','|'|'|'it''s'|plain,default='a=b'"`
+func TestApiValidatorQuoted(t *testing.T) {
+	ApiValidatorGeneric(t, `json:"sep" apivalidator:"enum=','|'|'|'it''s'|plain,default='a=b'"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			reflect.DeepEqual(fv.Enum, []string{",", "|", "it's", "plain"}),
+			fv.Default == "a=b",
+		}
+	})
+	ApiValidatorGeneric(t, `apivalidator:"split=',',layout='Jan 2, 2006'"`, func(fv *FieldValidator) []bool {
+		return []bool{
+			fv.Split == ",",
+			fv.Layout == "Jan 2, 2006",
+		}
+	})
+	ApiValidatorGeneric(t, `json:"-" apivalidator:""`, func(fv *FieldValidator) []bool {
+		return []bool{fv.Parsed}
+	})
+}
+
+func TestApiValidatorBadOptions(t *testing.T) {
+	for tag, expected := range map[string]string{
+		`apivalidator:"requird"`:             `unknown option "requird"`,
+		`apivalidator:"required,mn=3"`:       `unknown option "mn"`,
+		`apivalidator:"min"`:                 `no value for min`,
+		`apivalidator:"required=true"`:       `required takes no value`,
+		`apivalidator:"trim=yes"`:            `trim takes no value`,
+		`apivalidator:"lower,trim,upper"`:    `lower and upper exclude each other`,
+		`apivalidator:"maxsize=2TB"`:         `maxsize: "2TB" is not a size`,
+		`apivalidator:"maxsize=0"`:           `maxsize: "0" is not a size`,
+		`apivalidator:"contenttype=image"`:   `contenttype: "image" is not a media type`,
+		`apivalidator:"min=1,min=2"`:         `option min is repeated`,
+		`apivalidator:"enum='a|b"`:           `unterminated quote`,
+		`apivalidator:"required,,min=1"`:     `empty option`,
+		`apivalidator:"minitems=many"`:       `minitems: `,
+		`json:"x" apivalidator:"default=1,"`: `empty option`,
+	} {
+		fv := &FieldValidator{}
+		if err := fv.Parse(tag); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: error %q expected, got %v", tag, expected, err)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkWriter collects the validation blocks of a generated handler for the
+// param field, each of them answers 400 with its message and stops the
+// handler; with allErrors it adds a FieldError to errs and stops checking
+// the field instead.
+type checkWriter struct {
+	bytes.Buffer
+	field     string
+	allErrors bool
+}
+
+func (w *checkWriter) check(rule, cond, message string) {
+	w.checkStatus(rule, cond, message, "")
+}
+
+// checkStatus is check answering with the status expression other than
+// 400, unless the errors of all params are reported together.
+func (w *checkWriter) checkStatus(rule, cond, message, status string) {
+	switch {
+	case w.allErrors:
+		fmt.Fprintf(w, "if %s {\nerrs = append(errs, FieldError{Field: %q, Rule: %q, Message: %q})\n\nreturn\n}\n", cond, w.field, rule, message)
+	case status != "":
+		fmt.Fprintf(w, "if %s {\nhandleServerError(w, %s, errors.New(%q))\n\nreturn\n}\n", cond, status, message)
+	default:
+		fmt.Fprintf(w, "if %s {\nhandleBadRequest(w, %q)\n\nreturn\n}\n", cond, message)
+	}
+}
+
+// nested is a writer for the checks inside a block of w.
+func (w *checkWriter) nested() *checkWriter {
+	return &checkWriter{field: w.field, allErrors: w.allErrors}
+}
+
+// GenChecks validates the bound value in the generated code: pointers are
+// checked only when the param was sent, slices by their length and item by
+// item, TextUnmarshaler types by their presence and the other optional
+// values only when sent or defaulted. The rules referring to other fields
+// come around the field's own ones, so the errors follow the field order.
+func (def *FieldDef) GenChecks() string {
+	return def.genChecks(false)
+}
+
+func (def *FieldDef) genChecks(allErrors bool) string {
+	w := &checkWriter{field: def.ParamName(), allErrors: allErrors}
+	field := "params." + def.fieldPath()
+	meta := def.ValidatorMeta
+
+	def.genRequiredRefChecks(w)
+
+	switch {
+	case def.File:
+		def.genFileChecks(w, field)
+	case def.Slice:
+		def.genItemsChecks(w, field)
+
+		items := w.nested()
+		def.genValueChecks(items, "item", def.ParamName()+" item")
+
+		if items.Len() > 0 {
+			fmt.Fprintf(w, "for _, item := range %s {\n%s}\n", field, items.String())
+		}
+	case def.Pointer:
+		if meta.Required {
+			w.check("required", field+" == nil", def.ParamName()+" must me not empty")
+		}
+
+		value := w.nested()
+
+		if meta.Required && def.TypeName == "string" && !def.TextUnmarshaler {
+			value.check("required", fmt.Sprintf(`*%s == ""`, field), def.ParamName()+" must me not empty")
+		}
+
+		def.genValueChecks(value, "*"+field, def.ParamName())
+
+		if value.Len() > 0 {
+			fmt.Fprintf(w, "if %s != nil {\n%s}\n", field, value.String())
+		}
+	case def.TextUnmarshaler:
+		// the value may be of any type, so required means the param was sent
+		if meta.Required {
+			w.check("required", "!"+def.sentVar(), def.ParamName()+" must me not empty")
+		}
+	default:
+		if meta.Required {
+			w.check("required", zeroCheck(def.TypeName, field), def.ParamName()+" must me not empty")
+			def.genValueChecks(w, field, def.ParamName())
+
+			break
+		}
+
+		// an optional value is checked only when sent or defaulted
+		value := w.nested()
+		def.genValueChecks(value, field, def.ParamName())
+
+		if value.Len() > 0 {
+			fmt.Fprintf(w, "if %s {\n%s}\n", def.sentVar(), value.String())
+		}
+	}
+
+	def.genCompareRefChecks(w)
+
+	return w.String()
+}
+
+// genItemsChecks checks the number of the items of a slice, files included.
+func (def *FieldDef) genItemsChecks(w *checkWriter, field string) {
+	meta := def.ValidatorMeta
+
+	if meta.Required {
+		w.check("required", fmt.Sprintf("len(%s) == 0", field), def.ParamName()+" must me not empty")
+	}
+
+	if meta.IsMinItems {
+		w.check("minitems", fmt.Sprintf("len(%s) < %d", field, meta.MinItems), fmt.Sprintf("%s must have at least %d items", def.ParamName(), meta.MinItems))
+	}
+
+	if meta.IsMaxItems {
+		w.check("maxitems", fmt.Sprintf("len(%s) > %d", field, meta.MaxItems), fmt.Sprintf("%s must have at most %d items", def.ParamName(), meta.MaxItems))
+	}
+}
+
+// genFileChecks checks the number of the uploaded files, their size and
+// content type; a file over maxsize answers 413.
+func (def *FieldDef) genFileChecks(w *checkWriter, field string) {
+	meta := def.ValidatorMeta
+	value, subject := field, def.ParamName()
+
+	if def.Slice {
+		def.genItemsChecks(w, field)
+
+		value, subject = "item", def.ParamName()+" item"
+	} else if meta.Required {
+		w.check("required", field+" == nil", def.ParamName()+" must me not empty")
+	}
+
+	file := w.nested()
+
+	if meta.MaxSizeLit != "" {
+		file.checkStatus("maxsize", fmt.Sprintf("%s.Size > %d", value, meta.MaxSize), fmt.Sprintf("%s must be at most %s", subject, meta.MaxSizeLit), "http.StatusRequestEntityTooLarge")
+	}
+
+	if len(meta.ContentTypes) > 0 {
+		var types []string
+		for _, t := range meta.ContentTypes {
+			types = append(types, strconv.Quote(t))
+		}
+
+		file.check("contenttype", fmt.Sprintf("!validContentType(%s, %s)", value, strings.Join(types, ", ")), fmt.Sprintf("%s content type must be one of [%s]", subject, strings.Join(meta.ContentTypes, ", ")))
+	}
+
+	switch {
+	case file.Len() == 0:
+	case def.Slice:
+		fmt.Fprintf(w, "for _, item := range %s {\n%s}\n", field, file.String())
+	default:
+		fmt.Fprintf(w, "if %s != nil {\n%s}\n", field, file.String())
+	}
+}
+
+// genRequiredRefChecks checks the required_if and required_without rules.
+func (def *FieldDef) genRequiredRefChecks(w *checkWriter) {
+	meta := def.ValidatorMeta
+
+	if other := def.Refs["required_if"]; other != nil {
+		lit := meta.RequiredIfValue
+		if other.TypeName == "string" {
+			lit = strconv.Quote(lit)
+		}
+
+		cond := fmt.Sprintf("%s == %s", other.valueExpr(), lit)
+		if other.Pointer {
+			cond = fmt.Sprintf("params.%s != nil && %s", other.fieldPath(), cond)
+		}
+
+		w.check("required_if", cond+" && "+def.emptyCheck(), fmt.Sprintf("%s must me not empty when %s is %s", def.ParamName(), other.ParamName(), meta.RequiredIfValue))
+	}
+
+	if other := def.Refs["required_without"]; other != nil {
+		w.check("required_without", other.emptyCheck()+" && "+def.emptyCheck(), fmt.Sprintf("%s must me not empty when %s is empty", def.ParamName(), other.ParamName()))
+	}
+}
+
+// genCompareRefChecks checks the eqfield and gtfield rules, when both the
+// field and the other one were sent.
+func (def *FieldDef) genCompareRefChecks(w *checkWriter) {
+	for _, rule := range []struct {
+		option, timeCond, cond, message string
+	}{
+		{"eqfield", "!%s.Equal(%s)", "%s != %s", "%s must be equal to %s"},
+		{"gtfield", "!%s.After(%s)", "%s <= %s", "%s must be greater than %s"},
+	} {
+		other := def.Refs[rule.option]
+		if other == nil {
+			continue
+		}
+
+		var guards []string
+		if def.Pointer {
+			guards = append(guards, fmt.Sprintf("params.%s != nil", def.fieldPath()))
+		} else {
+			guards = append(guards, def.sentVar())
+		}
+
+		if other.Pointer {
+			guards = append(guards, fmt.Sprintf("params.%s != nil", other.fieldPath()))
+		} else {
+			guards = append(guards, other.sentVar())
+		}
+
+		value, format := def.valueExpr(), rule.cond
+		if def.TypeName == "time.Time" {
+			format = rule.timeCond
+
+			// (*params.To).After, not *(params.To.After)
+			if def.Pointer {
+				value = "(" + value + ")"
+			}
+		}
+
+		cond := fmt.Sprintf(format, value, other.valueExpr())
+		w.check(rule.option, strings.Join(append(guards, cond), " && "), fmt.Sprintf(rule.message, def.ParamName(), other.ParamName()))
+	}
+}
+
+// emptyCheck is the condition of the field being empty in the sense of
+// required.
+func (def *FieldDef) emptyCheck() string {
+	field := "params." + def.fieldPath()
+
+	switch {
+	case def.Slice:
+		return fmt.Sprintf("len(%s) == 0", field)
+	case def.Pointer:
+		return field + " == nil"
+	case def.TextUnmarshaler:
+		return "!" + def.sentVar()
+	}
+
+	return zeroCheck(def.TypeName, field)
+}
+
+// valueExpr is the bound value of a scalar field, dereferenced if it's a
+// pointer, which the caller checks for nil.
+func (def *FieldDef) valueExpr() string {
+	if def.Pointer {
+		return "*params." + def.fieldPath()
+	}
+
+	return "params." + def.fieldPath()
+}
+
+// genValueChecks checks a single value against the min, max and enum rules,
+// subject names it in the error messages.
+func (def *FieldDef) genValueChecks(w *checkWriter, value, subject string) {
+	meta := def.ValidatorMeta
+
+	switch def.TypeName {
+	case "time.Time":
+		// (*params.From).Before, not *(params.From.Before)
+		if strings.HasPrefix(value, "*") {
+			value = "(" + value + ")"
+		}
+
+		if meta.IsMin {
+			min, _ := time.Parse(meta.TimeLayout(), meta.MinLit)
+			w.check("min", fmt.Sprintf("%s.Before(time.Unix(%d, %d))", value, min.Unix(), min.Nanosecond()), fmt.Sprintf("%s must be >= %s", subject, meta.MinLit))
+		}
+
+		if meta.IsMax {
+			max, _ := time.Parse(meta.TimeLayout(), meta.MaxLit)
+			w.check("max", fmt.Sprintf("%s.After(time.Unix(%d, %d))", value, max.Unix(), max.Nanosecond()), fmt.Sprintf("%s must be <= %s", subject, meta.MaxLit))
+		}
+	case "time.Duration":
+		if meta.IsMin {
+			min, _ := time.ParseDuration(meta.MinLit)
+			w.check("min", fmt.Sprintf("%s < %d", value, min), fmt.Sprintf("%s must be >= %s", subject, meta.MinLit))
+		}
+
+		if meta.IsMax {
+			max, _ := time.ParseDuration(meta.MaxLit)
+			w.check("max", fmt.Sprintf("%s > %d", value, max), fmt.Sprintf("%s must be <= %s", subject, meta.MaxLit))
+		}
+	case "string":
+		if meta.IsMin {
+			w.check("min", fmt.Sprintf("len(%s) < %s", value, meta.MinLit), fmt.Sprintf("%s len must be >= %s", subject, meta.MinLit))
+		}
+
+		if meta.IsMax {
+			w.check("max", fmt.Sprintf("len(%s) > %s", value, meta.MaxLit), fmt.Sprintf("%s len must be <= %s", subject, meta.MaxLit))
+		}
+
+		// a string-based type like Login is matched as a string
+		str := value
+		if def.GoTypeName != "string" {
+			str = "string(" + value + ")"
+		}
+
+		if meta.Pattern != "" {
+			w.check("pattern", fmt.Sprintf("!%s.MatchString(%s)", def.PatternVar, str), fmt.Sprintf("%s must match %s", subject, meta.Pattern))
+		}
+
+		if meta.Format != "" {
+			w.check("format", fmt.Sprintf("!validFormat(%q, %s)", meta.Format, str), fmt.Sprintf("%s must be %s", subject, formatNames[meta.Format]))
+		}
+	default:
+		if meta.IsMin {
+			w.check("min", fmt.Sprintf("%s < %s", value, meta.MinLit), fmt.Sprintf("%s must be >= %s", subject, meta.MinLit))
+		}
+
+		if meta.IsMax {
+			w.check("max", fmt.Sprintf("%s > %s", value, meta.MaxLit), fmt.Sprintf("%s must be <= %s", subject, meta.MaxLit))
+		}
+	}
+
+	if len(meta.Enum) > 0 {
+		var conds []string
+		for _, e := range meta.Enum {
+			lit := e
+			if def.TypeName == "string" {
+				lit = strconv.Quote(e)
+			}
+
+			conds = append(conds, fmt.Sprintf("%s != %s", value, lit))
+		}
+
+		w.check("enum", strings.Join(conds, " && "), fmt.Sprintf("%s must be one of [%s]", subject, strings.Join(meta.Enum, ", ")))
+	}
+}
+
+// zeroCheck is the condition of value being the zero value of its type.
+func zeroCheck(typeName, value string) string {
+	switch typeName {
+	case "string":
+		return value + ` == ""`
+	case "bool":
+		return value + " == false"
+	case "time.Time":
+		return value + ".IsZero()"
+	}
+
+	return value + " == 0"
+}
@@ -2,19 +2,34 @@ package main
 
 import (
 	"bytes"
+	"embed"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"go/ast"
-	"go/parser"
+	"go/format"
+	"go/importer"
 	"go/token"
+	"go/types"
+	"io"
 	"log"
+	"math"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/mail"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"testing"
 	"text/template"
+	"time"
+	"unicode"
 )
 
 // HELPERS
@@ -30,54 +45,38 @@ func groupFunctionsByReceiver(fs []*FuncDef) map[string][]*FuncDef {
 	return ret
 }
 
-func associateFuncArgumentStruct(functions []*FuncDef, defs []*StructDef) *StructDef {
-	for _, f := range functions {
-		for _, d := range defs {
-			if f.ArgumentTypeName == d.Name {
-				f.ArgumentStruct = d
-			}
-		}
-	}
-
-	return nil
-}
-
 // EOF HELPERS
 
-func genServeHTTP(receiverName string, funcDefs []*FuncDef) string {
-	tmpl := template.Must(template.ParseFiles("handlers_gen/serve_http.template"))
-	w := bytes.NewBufferString("")
-	tmpl.Execute(w,
-		struct {
-			ReceiverName string
-			FuncDefs     []*FuncDef
-		}{
-			ReceiverName: receiverName,
-			FuncDefs:     funcDefs,
-		})
+//go:embed *.template
+var templates embed.FS
 
-	return w.String()
+func genServeHTTP(receiverName string, funcDefs []*FuncDef) string {
+	return genByTemplate("serve_http.template", struct {
+		ReceiverName string
+		FuncDefs     []*FuncDef
+	}{
+		ReceiverName: receiverName,
+		FuncDefs:     funcDefs,
+	})
 }
 
 func genHandlers(receiverName string, funcDefs []*FuncDef) string {
-	tmpl := template.Must(template.ParseFiles("handlers_gen/handle_method.template"))
-	w := bytes.NewBufferString("")
-	tmpl.Execute(w,
-		struct {
-			ReceiverName string
-			FuncDefs     []*FuncDef
-		}{
-			ReceiverName: receiverName,
-			FuncDefs:     funcDefs,
-		})
-
-	return w.String()
+	return genByTemplate("handle_method.template", struct {
+		ReceiverName string
+		FuncDefs     []*FuncDef
+	}{
+		ReceiverName: receiverName,
+		FuncDefs:     funcDefs,
+	})
 }
 
 func genByTemplate(templatePath string, vars interface{}) string {
-	tmpl := template.Must(template.ParseFiles("handlers_gen/" + templatePath))
+	tmpl := template.Must(template.ParseFS(templates, templatePath))
 	w := bytes.NewBufferString("")
-	tmpl.Execute(w, vars)
+
+	if err := tmpl.Execute(w, vars); err != nil {
+		log.Fatalln("template", templatePath, "failed:", err)
+	}
 
 	return w.String()
 }
@@ -86,6 +85,14 @@ type ApiGenArgs struct {
 	Url    string `json:"url"`
 	Auth   bool   `json:"auth"`
 	Method string `json:"method"`
+	// the handler answers with the errors of all params rather than the first one
+	AllErrors bool `json:"all_errors"`
+	// bytes of the files of a multipart body kept in memory, the rest go to
+	// temporary files; 0 is the -multipart-memory default
+	MultipartMemory int64 `json:"multipart_memory"`
+	// bytes of a multipart body the handler reads at most, the rest is
+	// answered with 413; 0 is the -max-body default
+	MaxBody int64 `json:"max_body"`
 }
 
 func (args *ApiGenArgs) String() string {
@@ -100,14 +107,131 @@ func (args *ApiGenArgs) SomeMethod() bool {
 	return args.Method != ""
 }
 
-func (args *ApiGenArgs) Parse(s string) {
-	ss := strings.TrimLeft(s, "apigen:api ")
-	data := []byte(ss)
-	json.Unmarshal(data, args)
+// Placeholders are the names of the {name} segments of the url, in order;
+// the path values are bound to the params of the same names.
+func (args *ApiGenArgs) Placeholders() []string {
+	var names []string
+
+	for _, segment := range strings.Split(args.Url, "/") {
+		if name, ok := placeholderName(segment); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// placeholderName is the name of a {name} url segment.
+func placeholderName(segment string) (string, bool) {
+	if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", false
+	}
+
+	name := segment[1 : len(segment)-1]
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-' {
+			return "", false
+		}
+	}
+
+	return name, true
+}
+
+// httpMethods are the verbs an annotation may restrict an endpoint to.
+var httpMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+const annotationMarker = "apigen:api"
+
+// Parse reads the annotation from the doc comment s: the marker followed by
+// a JSON object, which may span several lines and ends the comment. Unknown
+// keys, a method that is not an HTTP verb, a url not starting with / or
+// holding characters a path can't, and malformed or repeated {name}
+// placeholders of the url are errors.
+func (args *ApiGenArgs) Parse(s string) error {
+	i := strings.Index(s, annotationMarker)
+	if i < 0 {
+		return fmt.Errorf("no %s annotation", annotationMarker)
+	}
+
+	annotation := s[i+len(annotationMarker):]
+	dec := json.NewDecoder(strings.NewReader(annotation))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(args); err != nil {
+		if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+			return fmt.Errorf("unknown key %s, expected url, auth, method, all_errors, multipart_memory or max_body", field)
+		}
+
+		if err == io.EOF {
+			return fmt.Errorf("no JSON object after %s", annotationMarker)
+		}
+
+		return err
+	}
+
+	if rest := strings.TrimSpace(annotation[dec.InputOffset():]); rest != "" {
+		line, _, _ := strings.Cut(rest, "\n")
+
+		return fmt.Errorf("unexpected %q after the JSON object", line)
+	}
+
+	if !strings.HasPrefix(args.Url, "/") {
+		return fmt.Errorf("url %q must start with /", args.Url)
+	}
+
+	// the query and the fragment are not a part of the path matched
+	for _, c := range args.Url {
+		if unicode.IsSpace(c) || unicode.IsControl(c) || strings.ContainsRune(`"\?#`, c) {
+			return fmt.Errorf("url %q: %q is not allowed in a path", args.Url, c)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, segment := range strings.Split(args.Url, "/") {
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+
+		name, ok := placeholderName(segment)
+		if !ok {
+			return fmt.Errorf("url %q: segment %s must be a whole {name} placeholder of letters, digits, _ and -", args.Url, segment)
+		}
+
+		if seen[name] {
+			return fmt.Errorf("url %q: placeholder {%s} is repeated", args.Url, name)
+		}
+
+		seen[name] = true
+	}
+
+	if args.Method != "" {
+		valid := false
+		for _, m := range httpMethods {
+			valid = valid || args.Method == m
+		}
+
+		if !valid {
+			return fmt.Errorf("method %q is not one of %s", args.Method, strings.Join(httpMethods, ", "))
+		}
+	}
+
+	if args.MultipartMemory < 0 {
+		return fmt.Errorf("multipart_memory %d must not be negative", args.MultipartMemory)
+	}
+
+	if args.MaxBody < 0 {
+		return fmt.Errorf("max_body %d must not be negative", args.MaxBody)
+	}
 
-	//if args.Method == "" {
-	//	args.Method = "GET"
-	//}
+	return nil
 }
 
 type FieldValidator struct {
@@ -122,226 +246,567 @@ type FieldValidator struct {
 	Default string
 	IsMin   bool
 	IsMax   bool
-	//для типа int
+	//для чисел
 	//>= X
 	//для строк
 	//len(str) >=
-	Min int
-	Max int
+	Min float64
+	Max float64
+	// значения как они записаны в теге, чтобы сгенерированный код сравнивал точно
+	MinLit string
+	MaxLit string
+	// для срезов: количество элементов и разделитель значений внутри одного параметра
+	IsMinItems bool
+	IsMaxItems bool
+	MinItems   int
+	MaxItems   int
+	Split      string
+	// для time.Time: формат или имя константы пакета time, по умолчанию RFC3339
+	Layout string
+	// правила, ссылающиеся на другие поля той же структуры по их имени в Go:
+	// обязательно, если поле RequiredIf равно RequiredIfValue; обязательно,
+	// если поле RequiredWithout пустое; равно полю EqField; больше поля GtField
+	RequiredIf      string
+	RequiredIfValue string
+	RequiredWithout string
+	EqField         string
+	GtField         string
+	// для строк: регулярное выражение, которому должно соответствовать значение
+	Pattern string
+	// для строк: один из форматов formatNames, email, uuid...
+	Format string
+	// нормализация значения до разбора и проверок: обрезать пробелы по краям,
+	// схлопнуть пробелы внутри, привести к нижнему или верхнему регистру
+	Trim     bool
+	Collapse bool
+	Lower    bool
+	Upper    bool
+	// для файлов: наибольший размер в байтах, как он записан в теге (2MB),
+	// и допустимые типы содержимого, image/png или image/*
+	MaxSize      int64
+	MaxSizeLit   string
+	ContentTypes []string
+	// опции тега в порядке записи, по ним проверяется, какие из них
+	// применимы к полю
+	options []string
 }
 
 func (validator *FieldValidator) HasDefault() bool {
 	return len(validator.Default) > 0
 }
 
+// timeLayouts are the layout constants of package time a tag may refer to by name.
+var timeLayouts = map[string]string{
+	"Layout":      time.Layout,
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// TimeLayout is the layout time.Time values are parsed with.
+func (validator *FieldValidator) TimeLayout() string {
+	if validator.Layout == "" {
+		return time.RFC3339
+	}
+
+	if layout, ok := timeLayouts[validator.Layout]; ok {
+		return layout
+	}
+
+	return validator.Layout
+}
+
+// LayoutExpr is TimeLayout as an expression of the generated code.
+func (validator *FieldValidator) LayoutExpr() string {
+	if validator.Layout == "" {
+		return "time.RFC3339"
+	}
+
+	if _, ok := timeLayouts[validator.Layout]; ok {
+		return "time." + validator.Layout
+	}
+
+	return strconv.Quote(validator.Layout)
+}
+
+// Parse reads the apivalidator key of the struct tag s: comma separated
+// options, each of them a name or name=value. A value may be put in single
+// quotes to hold commas, pipes or equals signs, a quote inside the quotes is
+// doubled:
+//
+//	enum='a,b'|'it''s'
+//
+// Unknown and repeated options are errors.
 func (validator *FieldValidator) Parse(s string) error {
-	if !strings.Contains(s, "apivalidator:") {
+	body, ok := reflect.StructTag(s).Lookup("apivalidator")
+	if !ok {
+		return nil
+	}
+
+	if body == "" {
+		validator.Parsed = true
+
 		return nil
 	}
 
-	body := s[len(`"apivalidator:"`)-1 : len(s)-1]
-	xs := strings.Split(body, ",")
+	xs, err := splitUnquoted(body, ',')
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+
+	for i := 0; i < len(xs); i++ {
+		x := xs[i]
+
+		// split=, : the comma separator itself was taken for the options delimiter
+		if x == "split=" && i+1 < len(xs) && xs[i+1] == "" {
+			xs[i+1] = "','"
+			x += xs[i+1]
+			i++
+		}
 
-	for _, x := range xs {
-		bundle := strings.Split(x, "=")
+		key, value, hasValue := strings.Cut(x, "=")
 
-		if len(bundle) < 1 {
-			return fmt.Errorf("no key")
+		if key == "" {
+			return fmt.Errorf("empty option in %q", body)
 		}
 
-		if len(bundle) < 2 && bundle[0] != "required" {
-			return fmt.Errorf("no value for " + bundle[0])
+		if seen[key] {
+			return fmt.Errorf("option %s is repeated", key)
 		}
 
-		switch bundle[0] {
-		// required
-		//paramname
-		//enum
-		//default
-		//min
-		//max
-		case "required":
-			validator.Required = true
-		case "paramname":
-			validator.ParamName = bundle[1]
-		case "enum":
-			split := strings.Split(bundle[1], "|")
+		seen[key] = true
+		validator.options = append(validator.options, key)
+
+		if set, ok := flagOptions[key]; ok {
+			if hasValue {
+				return fmt.Errorf("%s takes no value", key)
+			}
+
+			set(validator)
 
-			if len(split) < 1 {
-				return fmt.Errorf("no enum values")
+			continue
+		}
+
+		if !hasValue {
+			if _, known := tagOptions[key]; known {
+				return fmt.Errorf("no value for %s", key)
 			}
 
-			validator.Enum = split
-		case "default":
-			validator.Default = bundle[1]
-		case "min":
-			min, e := strconv.Atoi(bundle[1])
+			return fmt.Errorf("unknown option %q", key)
+		}
 
-			if e != nil {
-				return e
+		if key == "enum" || key == "contenttype" {
+			items, err := splitUnquoted(value, '|')
+			if err != nil {
+				return err
 			}
 
-			validator.IsMin = true
-			validator.Min = min
-		case "max":
-			max, e := strconv.Atoi(bundle[1])
+			for _, item := range items {
+				item = unquoteTagValue(item)
+
+				if key == "enum" {
+					validator.Enum = append(validator.Enum, item)
+
+					continue
+				}
+
+				if mediaType, subtype, ok := strings.Cut(item, "/"); !ok || mediaType == "" || subtype == "" {
+					return fmt.Errorf("contenttype: %q is not a media type like image/png", item)
+				}
 
-			if e != nil {
-				return e
+				validator.ContentTypes = append(validator.ContentTypes, strings.ToLower(item))
 			}
 
-			validator.IsMax = true
-			validator.Max = max
+			continue
+		}
+
+		set, known := tagOptions[key]
+		if !known {
+			return fmt.Errorf("unknown option %q", key)
+		}
+
+		if err := set(validator, unquoteTagValue(value)); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
 		}
 	}
 
+	if validator.Lower && validator.Upper {
+		return fmt.Errorf("lower and upper exclude each other")
+	}
+
 	validator.Parsed = true
 
 	return nil
 }
 
-type FuncDef struct {
-	CommentText      string
-	ApiArgs          *ApiGenArgs
-	ReceiverName     string
-	MethodName       string
-	ArgumentName     string
-	ArgumentTypeName string
-	ArgumentStruct   *StructDef
-	ResulTypeName    string
+// flagOptions set the options without a value.
+var flagOptions = map[string]func(validator *FieldValidator){
+	"required": func(validator *FieldValidator) { validator.Required = true },
+	"trim":     func(validator *FieldValidator) { validator.Trim = true },
+	"collapse": func(validator *FieldValidator) { validator.Collapse = true },
+	"lower":    func(validator *FieldValidator) { validator.Lower = true },
+	"upper":    func(validator *FieldValidator) { validator.Upper = true },
 }
 
-func (p *FuncDef) TemplateMapString() string {
-	return p.ArgumentStruct.TemplateMapString()
-}
+// otherOption is the first option of the tag which is not one of allowed,
+// empty if there is none.
+func (validator *FieldValidator) otherOption(allowed ...string) string {
+	for _, option := range validator.options {
+		found := false
+		for _, a := range allowed {
+			found = found || option == a
+		}
 
-type FieldDef struct {
-	Name          string
-	TypeName      string
-	Tag           reflect.StructTag
-	ValidatorMeta *FieldValidator
-	Value         interface{}
-}
+		if !found {
+			return option
+		}
+	}
 
-func (def *FieldDef) ParamName() string {
-	var key string
+	return ""
+}
 
-	if len(def.ValidatorMeta.ParamName) > 0 {
-		key = def.ValidatorMeta.ParamName
-	} else {
-		key = strings.ToLower(def.Name)
+// joinOptions lists the option names for a message: a, b and c.
+func joinOptions(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
 	}
 
-	return key
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
 }
 
-func (def *FieldDef) GenInputValue() string {
-	return fmt.Sprintf(`{ ParamName:  "%s", Def: "%s", TypeName: "%s", HasDefault: %v }`,
-		ParamName(def.ValidatorMeta.ParamName, def.Name),
-		def.ValidatorMeta.Default,
-		def.TypeName,
-		def.ValidatorMeta.HasDefault(),
-	)
-}
+// Normalize lists the normalization options of the tag in the order the
+// generated code applies them.
+func (validator *FieldValidator) Normalize() []string {
+	var options []string
+
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"trim", validator.Trim},
+		{"collapse", validator.Collapse},
+		{"lower", validator.Lower},
+		{"upper", validator.Upper},
+	} {
+		if option.set {
+			options = append(options, option.name)
+		}
+	}
 
-func (def *FieldDef) GenParamKeyVal() string {
-	return fmt.Sprintf(`%s:  inputMap["%s"].(%s)`,
-		def.Name,
-		ParamName(def.ValidatorMeta.ParamName, def.Name),
-		def.TypeName,
-	)
+	return options
 }
 
-type StructDef struct {
-	Name   string
-	Fields []*FieldDef
-}
+// tagOptions set the options with a single value, enum, contenttype and the
+// options without a value are handled by Parse itself.
+var tagOptions = map[string]func(validator *FieldValidator, value string) error{
+	"enum":        nil,
+	"contenttype": nil,
+	"paramname": func(validator *FieldValidator, value string) error {
+		validator.ParamName = value
 
-func (def *StructDef) ParseMeta() error {
-	for _, f := range def.Fields {
-		t := f.Tag
-		f.ValidatorMeta = &FieldValidator{}
-		e := f.ValidatorMeta.Parse(string(t))
+		return nil
+	},
+	"default": func(validator *FieldValidator, value string) error {
+		validator.Default = value
+
+		return nil
+	},
+	// min and max of dates and durations aren't numbers, the literals
+	// are checked against the field type by checkLiterals
+	"min": func(validator *FieldValidator, value string) error {
+		validator.IsMin = true
+		validator.Min, _ = strconv.ParseFloat(value, 64)
+		validator.MinLit = value
+
+		return nil
+	},
+	"max": func(validator *FieldValidator, value string) error {
+		validator.IsMax = true
+		validator.Max, _ = strconv.ParseFloat(value, 64)
+		validator.MaxLit = value
+
+		return nil
+	},
+	"minitems": func(validator *FieldValidator, value string) error {
+		n, err := strconv.Atoi(value)
+		validator.IsMinItems = true
+		validator.MinItems = n
+
+		return err
+	},
+	"maxitems": func(validator *FieldValidator, value string) error {
+		n, err := strconv.Atoi(value)
+		validator.IsMaxItems = true
+		validator.MaxItems = n
+
+		return err
+	},
+	"split": func(validator *FieldValidator, value string) error {
+		validator.Split = value
 
-		if e != nil {
-			return e
+		return nil
+	},
+	"layout": func(validator *FieldValidator, value string) error {
+		validator.Layout = value
+
+		return nil
+	},
+	"required_if": func(validator *FieldValidator, value string) error {
+		field, fieldValue, ok := strings.Cut(value, ":")
+		if !ok || field == "" {
+			return fmt.Errorf("required_if must be Field:value, got %q", value)
 		}
-	}
 
-	return nil
-}
+		validator.RequiredIf = field
+		validator.RequiredIfValue = fieldValue
 
-func (def *StructDef) TemplateMap() map[string]interface{} {
-	ret := map[string]interface{}{}
-	for _, f := range def.Fields {
-		ret[f.ParamName()] = f.ToValidatorExpression()
-	}
+		return nil
+	},
+	"required_without": func(validator *FieldValidator, value string) error {
+		validator.RequiredWithout = value
 
-	return ret
+		return nil
+	},
+	"eqfield": func(validator *FieldValidator, value string) error {
+		validator.EqField = value
+
+		return nil
+	},
+	"gtfield": func(validator *FieldValidator, value string) error {
+		validator.GtField = value
+
+		return nil
+	},
+	"format": func(validator *FieldValidator, value string) error {
+		if _, ok := formatNames[value]; !ok {
+			return fmt.Errorf("unknown format %q, expected email, uuid, url, ip or hostname", value)
+		}
+
+		validator.Format = value
+
+		return nil
+	},
+	"pattern": func(validator *FieldValidator, value string) error {
+		_, err := regexp.Compile(value)
+		validator.Pattern = value
+
+		return err
+	},
+	"maxsize": func(validator *FieldValidator, value string) error {
+		size, err := parseSize(value)
+		validator.MaxSize = size
+		validator.MaxSizeLit = value
+
+		return err
+	},
 }
 
-// TemplateMapString should be according to TemplateMap
-func (def *StructDef) TemplateMapString() string {
-	w := bytes.NewBufferString("")
-	w.WriteString("map[string]interface{}{")
+// sizeUnits are the suffixes of maxsize, 2MB is 2<<20 bytes.
+var sizeUnits = []struct {
+	suffix string
+	shift  uint
+}{
+	{"GB", 30},
+	{"MB", 20},
+	{"KB", 10},
+	{"B", 0},
+}
 
-	for _, f := range def.Fields {
-		fmt.Fprintf(w, `"%s": "%s",`, f.ParamName(), f.ToValidatorExpression())
+// parseSize reads a positive number of bytes with an optional unit: 512,
+// 64KB, 2MB.
+func parseSize(s string) (int64, error) {
+	digits, shift := s, uint(0)
+
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			digits, shift = strings.TrimSuffix(s, unit.suffix), unit.shift
+
+			break
+		}
 	}
 
-	w.WriteString("}")
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("%q is not a size like 512, 64KB or 2MB", s)
+	}
 
-	return w.String()
+	return n << shift, nil
 }
 
-func (def *StructDef) InputMap(t *testing.T, values url.Values) map[string]interface{} {
-	ret := map[string]interface{}{}
-	for _, f := range def.Fields {
-		val, _ := f.ToInputValue(t, values)
+// formatNames are the formats known to validFormat of the generated code,
+// with the way the error messages name them.
+var formatNames = map[string]string{
+	"email":    "a valid email address",
+	"uuid":     "a valid UUID",
+	"url":      "a valid URL",
+	"ip":       "a valid IP address",
+	"hostname": "a valid hostname",
+}
 
-		if val != nil {
-			ret[f.ParamName()] = val
+// fieldRefs lists the cross-field options of the tag in the order they are
+// checked, with the names of the fields they refer to.
+func (validator *FieldValidator) fieldRefs() [][2]string {
+	var refs [][2]string
+
+	for _, ref := range [][2]string{
+		{"required_if", validator.RequiredIf},
+		{"required_without", validator.RequiredWithout},
+		{"eqfield", validator.EqField},
+		{"gtfield", validator.GtField},
+	} {
+		if ref[1] != "" {
+			refs = append(refs, ref)
 		}
 	}
 
-	return ret
+	return refs
 }
 
-// GENERATE PART
+// splitUnquoted splits s at the sep characters outside of single quotes,
+// the pieces keep their quotes.
+func splitUnquoted(s string, sep byte) ([]string, error) {
+	var pieces []string
+
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			quoted = !quoted // a doubled quote toggles twice
+		case s[i] == sep && !quoted:
+			pieces = append(pieces, s[start:i])
+			start = i + 1
+		}
+	}
 
-func ToInputValue(paramName, def, typeName string, hasDefault bool, values url.Values) (interface{}, error) {
-	var ret interface{}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
 
-	has := values.Has(paramName)
-	sv := values.Get(paramName)
+	return append(pieces, s[start:]), nil
+}
 
-	if has && len(sv) < 1 {
-		has = false
+// unquoteTagValue drops the single quotes of a value split by splitUnquoted
+// and turns the doubled quotes inside them into one.
+func unquoteTagValue(s string) string {
+	if !strings.Contains(s, "'") {
+		return s
 	}
 
-	if !has {
-		if hasDefault {
-			sv = def
-		} else {
-			return nil, nil
+	var sb strings.Builder
+
+	quoted := false
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\'' {
+			sb.WriteByte(s[i])
+
+			continue
 		}
-	}
 
-	switch typeName {
-	case "string":
-		ret = sv
-	case "int":
-		fallthrough
-	case "uint64":
-		var err error
-		ret, err = strconv.Atoi(sv)
+		if quoted && i+1 < len(s) && s[i+1] == '\'' {
+			sb.WriteByte('\'')
+			i++
 
-		if err != nil {
-			return nil, fmt.Errorf("!strconv.Atoi(sv)")
+			continue
 		}
+
+		quoted = !quoted
 	}
 
-	return ret, nil
+	return sb.String()
+}
+
+type FuncDef struct {
+	CommentText  string
+	ApiArgs      *ApiGenArgs
+	ReceiverName string
+	// ReceiverName with the type parameters of a generic receiver: Api[T]
+	ReceiverType string
+	// the method takes a context.Context first
+	Context          bool
+	MethodName       string
+	ArgumentName     string
+	ArgumentTypeName string
+	ArgumentPointer  bool
+	// nil when the method takes no parameters struct
+	ArgumentStruct *StructDef
+	// empty when the method returns only an error, the handler answers 204
+	ResulTypeName string
+	// the call of the Validate hook of the parameters struct, if it has one
+	ValidateCall string
+	// bytes of a multipart body kept in memory by the handler
+	MultipartMemory int64
+	// bytes of a multipart body read by the handler at most
+	MaxBody int64
+}
+
+type FieldDef struct {
+	Name string
+	// selector of the field from the parameters struct, Pagination.Limit
+	// for a field promoted from an embedded Pagination
+	Path string
+	// of the field declaration, for diagnostics
+	Pos token.Pos
+	// param names of the enclosing nested structs, filter. for the Status
+	// field of a nested Filter struct, bound from filter.status
+	Prefix string
+	// key of the field in a JSON body, dotted for the fields of nested
+	// structs like Prefix: filter.status; empty for json:"-"
+	JSONName string
+	// basic type the value is converted to and validated as: int, string...
+	TypeName string
+	// declared type as written in the generated code, when it differs from
+	// TypeName: a named type like Login or an imported one like pkg.Login
+	GoTypeName string
+	// *int, *string...: nil when the param is absent, validated when present
+	Pointer bool
+	// []int, []string...: bound from repeated keys, TypeName is the item type
+	Slice bool
+	// the declared type implements encoding.TextUnmarshaler: the param is
+	// bound as a string and handed to UnmarshalText
+	TextUnmarshaler bool
+	// *multipart.FileHeader or a slice of them, bound from the files of a
+	// multipart body; TypeName is file
+	File bool
+	// the enum comes from the typed constants of the declared type
+	ConstEnum     bool
+	Tag           reflect.StructTag
+	ValidatorMeta *FieldValidator
+	Value         interface{}
+	// the fields the cross-field options refer to, by option name,
+	// resolved by newStructDef
+	Refs map[string]*FieldDef
+	// package variable of the generated file holding the compiled pattern
+	PatternVar string
+	// the checks read the sentVar flag of the field
+	sentUsed bool
+	// the checks are skipped by the invalidVar flag of the field
+	invalidUsed bool
+	// flagName, unique in the parameters struct
+	flag string
+}
+
+func (def *FieldDef) ParamName() string {
+	return def.Prefix + ParamName(def.ValidatorMeta.ParamName, def.Name)
 }
 
 func ParamName(paramName, name string) string {
@@ -356,313 +821,1206 @@ func ParamName(paramName, name string) string {
 	return key
 }
 
-type InputValue struct {
-	ParamName  string
-	Def        string
-	TypeName   string
-	HasDefault bool
+func (def *FieldDef) fieldPath() string {
+	if def.Path == "" {
+		return def.Name
+	}
+
+	return def.Path
+}
+
+// GenParamAssign fills the field from r.Form with the parsed value, a bad
+// value answers 400 and stops the handler.
+func (def *FieldDef) GenParamAssign() string {
+	return def.genParamAssign(false)
 }
 
-func InputMap(fields []InputValue, values url.Values) map[string]interface{} {
-	ret := map[string]interface{}{}
-	for _, f := range fields {
-		val, _ := ToInputValue(f.ParamName, f.Def, f.TypeName, f.HasDefault, values)
+// genParamAssign with allErrors adds a FieldError for a bad value to errs
+// and marks the field invalid instead, then goes on with the next fields.
+func (def *FieldDef) genParamAssign(allErrors bool) string {
+	path := "params." + def.fieldPath()
+	meta := def.ValidatorMeta
 
-		if val != nil {
-			ret[f.ParamName] = val
+	// the statements run when the value doesn't parse
+	fail := func(message, next string) string {
+		if allErrors {
+			report := fmt.Sprintf("errs = append(errs, FieldError{Field: %q, Rule: \"type\", Message: %s})\n", def.ParamName(), message)
+
+			// the checks of a value that failed to parse are skipped
+			if def.invalidUsed {
+				report += def.invalidVar() + " = true\n"
+			}
+
+			return report + next
 		}
+
+		return fmt.Sprintf("handleBadRequest(w, %s)\n\nreturn\n", message)
 	}
 
-	return ret
-}
+	if def.File && def.Slice {
+		return fmt.Sprintf("%s = formFiles(r, %q, %q)", path, def.ParamName(), def.bracketKey())
+	}
 
-// EOF GENERATE PART
+	if def.File {
+		return fmt.Sprintf("%s = formFile(r, %q, %q)", path, def.ParamName(), def.bracketKey())
+	}
 
-func (def *FieldDef) String() string {
-	return fmt.Sprintf("%s %s %s\n", def.Name, def.TypeName, def.Tag)
-}
+	if def.Slice {
+		items := fmt.Sprintf("formItems(r.Form, %q, %q, %q, %q%s)", def.ParamName(), def.bracketKey(), meta.Split, meta.Default, def.normalizeArgs())
+		parse, val, message := def.genParse("item")
 
-func (def *FieldDef) ToValidatorExpression() string {
-	if !def.ValidatorMeta.Parsed {
-		return "-"
-	}
+		if parse == "" && val == "item" {
+			return fmt.Sprintf("%s = %s", path, items)
+		}
 
-	var sb []string
+		if parse != "" {
+			parse += fmt.Sprintf("if err != nil {\n%s}\n", fail(message, "\nbreak\n"))
+		}
 
-	if def.ValidatorMeta.Required {
-		sb = append(sb, "required")
+		return fmt.Sprintf("for _, item := range %s {\n%s%s = append(%s, %s)\n}", items, parse, path, path, val)
 	}
 
-	sb = append(sb, fmt.Sprintf("type(%s)", def.TypeName))
+	parse, val, message := def.genParse("sv")
+	assign := fmt.Sprintf("%s = %s\n", path, val)
 
-	if len(def.ValidatorMeta.Enum) > 0 {
-		sb = append(sb, "in("+strings.Join(def.ValidatorMeta.Enum, "|")+")")
+	if def.Pointer && val == "val" {
+		assign = fmt.Sprintf("%s = &val\n", path)
+	} else if def.Pointer {
+		assign = fmt.Sprintf("val := %s\n%s = &val\n", val, path)
 	}
 
-	if def.ValidatorMeta.IsMin {
-		switch def.TypeName {
-		case "string":
-			sb = append(sb, fmt.Sprintf("minstringlength(%d)", def.ValidatorMeta.Min))
-		case "int":
-			fallthrough
-		case "uint64":
-			if !def.ValidatorMeta.IsMax {
-				sb = append(sb, fmt.Sprintf("range(%d|)", def.ValidatorMeta.Min))
-			} else {
-				sb = append(sb, fmt.Sprintf("range(%d|%d)", def.ValidatorMeta.Min, def.ValidatorMeta.Max))
-			}
-		}
+	if def.sentUsed {
+		assign += def.sentVar() + " = true\n"
 	}
 
-	if def.ValidatorMeta.IsMax {
-		switch def.TypeName {
-		case "string":
-			sb = append(sb, fmt.Sprintf("maxstringlength(%d)", def.ValidatorMeta.Max))
-		case "int":
-			fallthrough
-		case "uint64":
-			if !def.ValidatorMeta.IsMin {
-				sb = append(sb, fmt.Sprintf("range(|%d)", def.ValidatorMeta.Max))
-			}
-		}
+	switch {
+	case parse == "":
+	case allErrors:
+		assign = fmt.Sprintf("%sif err != nil {\n%s} else {\n%s}\n", parse, fail(message, ""), assign)
+	default:
+		assign = fmt.Sprintf("%sif err != nil {\n%s}\n%s", parse, fail(message, ""), assign)
 	}
 
-	return strings.Join(sb, ",")
+	return fmt.Sprintf("if sv, ok := formValue(r.Form, %q, %q, %q, %v%s); ok {\n%s}", def.ParamName(), def.bracketKey(), meta.Default, def.Pointer, def.normalizeArgs(), assign)
 }
 
-func (def *FieldDef) ToInputValue(t *testing.T, values url.Values) (interface{}, error) {
-	var ret interface{}
+// normalizeArgs are the trailing arguments of formValue and formItems naming
+// the normalization options of the field.
+func (def *FieldDef) normalizeArgs() string {
+	var args strings.Builder
 
-	if !def.ValidatorMeta.Parsed {
-		return nil, fmt.Errorf("!def.ValidatorMeta.Parsed")
+	for _, option := range def.ValidatorMeta.Normalize() {
+		fmt.Fprintf(&args, ", %q", option)
 	}
 
-	paramName := def.ParamName()
-	has := values.Has(paramName)
-	sv := values.Get(paramName)
+	return args.String()
+}
+
+// genParse parses the string held by src as the type of the field, it
+// returns the statements doing so and setting err, the expression of the
+// parsed value and the one of the message about err. Strings are not parsed.
+func (def *FieldDef) genParse(src string) (string, string, string) {
+	// the declared type of a value parsed as TypeName
+	convert := func(val string) string {
+		if def.GoTypeName == def.TypeName {
+			return val
+		}
 
-	if has && len(sv) < 1 {
-		has = false
+		return def.GoTypeName + "(" + val + ")"
 	}
 
-	t.Logf("paramName: %v", paramName)
-	t.Logf("has: %v", has)
-	t.Logf("sv: %v", sv)
+	bits := strings.TrimLeft(def.TypeName, "abcdefghijklmnopqrstuvwxyz")
+	if bits == "" {
+		bits = "0"
+	}
 
-	if !has {
-		if def.ValidatorMeta.HasDefault() {
-			sv = def.ValidatorMeta.Default
-		} else {
-			return nil, nil
-		}
+	parseError := fmt.Sprintf("parseError(%q, %q, err)", def.ParamName(), def.TypeName)
+
+	switch {
+	case def.TextUnmarshaler:
+		return fmt.Sprintf("var val %s\nerr := val.UnmarshalText([]byte(%s))\n", def.GoTypeName, src), "val", strconv.Quote(def.ParamName()+" is invalid: ") + "+err.Error()"
+	case def.TypeName == "string":
+		return "", convert(src), ""
+	case def.TypeName == "bool":
+		return fmt.Sprintf("b, err := strconv.ParseBool(%s)\n", src), convert("b"), parseError
+	case def.TypeName == "time.Time":
+		message := strconv.Quote(fmt.Sprintf("%s must be formatted as %s", def.ParamName(), def.ValidatorMeta.TimeLayout()))
+
+		return fmt.Sprintf("t, err := time.Parse(%s, %s)\n", def.ValidatorMeta.LayoutExpr(), src), "t", message
+	case def.TypeName == "time.Duration":
+		return fmt.Sprintf("d, err := time.ParseDuration(%s)\n", src), "d", strconv.Quote(def.ParamName() + " must be a duration like 1h30m")
+	case strings.HasPrefix(def.TypeName, "int"):
+		return fmt.Sprintf("n, err := strconv.ParseInt(%s, 10, %s)\n", src, bits), def.GoTypeName + "(n)", parseError
+	case strings.HasPrefix(def.TypeName, "uint"):
+		return fmt.Sprintf("n, err := strconv.ParseUint(%s, 10, %s)\n", src, bits), def.GoTypeName + "(n)", parseError
 	}
 
-	switch def.TypeName {
-	case "string":
-		ret = sv
-	case "int":
-		fallthrough
-	case "uint64":
-		var err error
-		ret, err = strconv.Atoi(sv)
+	// float32 and float64
+	return fmt.Sprintf("f, err := parseFloat(%s, %s)\n", src, bits), def.GoTypeName + "(f)", parseError
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("!strconv.Atoi(sv)")
-		}
+// parsed tells whether the value of the field may fail to parse.
+func (def *FieldDef) parsed() bool {
+	return !def.File && (def.TextUnmarshaler || def.TypeName != "string")
+}
+
+// bracketKey spells the param name of a nested field with brackets,
+// filter[age][min] for filter.age.min, it is empty for the other fields.
+func (def *FieldDef) bracketKey() string {
+	if def.Prefix == "" {
+		return ""
 	}
 
-	return ret, nil
+	parts := strings.Split(def.ParamName(), ".")
+
+	return parts[0] + "[" + strings.Join(parts[1:], "][") + "]"
 }
 
-func inspectFuncSignature(f ast.Node, funcCall *FuncDef) {
-	ast.Inspect(f, func(n ast.Node) bool {
-		if fd, ok := n.(*ast.FuncDecl); ok {
-			funcCall.MethodName = fd.Name.Name
-			funcCall.CommentText = fd.Doc.Text()
-			funcCall.ApiArgs = &ApiGenArgs{}
-			funcCall.ApiArgs.Parse(funcCall.CommentText)
+// jsonKind is the JSON type of the values of the field in a body: dates,
+// durations and TextUnmarshaler types are written as strings.
+func (def *FieldDef) jsonKind() string {
+	switch def.TypeName {
+	case "bool":
+		return "boolean"
+	case "string", "time.Time", "time.Duration":
+		return "string"
+	}
 
-			// param type
-			for _, param := range fd.Type.Params.List {
-				funcCall.ArgumentName = fmt.Sprintf("%s\n", param.Names[0])
-				funcCall.ArgumentTypeName = fmt.Sprintf("%+v", param.Type)
-			}
+	return "number"
+}
 
-			// result type
-			for _, result := range fd.Type.Results.List {
-				switch recType := result.Type.(type) {
-				case *ast.StarExpr:
-					if si, ok := recType.X.(*ast.Ident); ok {
-						funcCall.ResulTypeName = si.Name
-					}
-				case *ast.Ident:
-					funcCall.ResulTypeName = recType.Name
-				}
-				break // only first iteration
-			}
+// invalidVar names the flag of a handler reporting all errors telling that
+// the param failed to parse, so that its checks are skipped.
+func (def *FieldDef) invalidVar() string {
+	return "invalid" + def.flagName()
+}
 
-			for _, receiver := range fd.Recv.List {
-				switch recType := receiver.Type.(type) {
-				case *ast.StarExpr:
-					if si, ok := recType.X.(*ast.Ident); ok {
-						funcCall.ReceiverName = si.Name
-					}
-				case *ast.Ident:
-					funcCall.ReceiverName = recType.Name
-				}
-			}
-		}
-		return true
-	})
+// flagName is the part of the flag names of the field after sent or
+// invalid: the field path with the dots turned into underscores,
+// Filter_Age for Filter.Age, numbered by newStructDef on a clash.
+func (def *FieldDef) flagName() string {
+	if def.flag != "" {
+		return def.flag
+	}
+
+	return strings.ReplaceAll(def.fieldPath(), ".", "_")
 }
 
-//func populateStructs(s []*StructDef, f []*FuncDef) *StructDef {
-//	for _, v := range s {
-//		if v.Name == name {
-//			return v
-//		}
+// sentVar names the flag of the generated handler telling that the param was
+// sent or defaulted. The checks asking for it are generated before the
+// binding code, which declares and sets the flags asked for.
+func (def *FieldDef) sentVar() string {
+	def.sentUsed = true
+
+	return "sent" + def.flagName()
+}
+
+type StructDef struct {
+	Name   string
+	Fields []*FieldDef
+	// statements allocating pointer-embedded structs before the fields are set
+	Allocs []string
+	// the handler reports the errors of all params at once
+	AllErrors bool
+	// package variable of the generated file holding the JSON keys of the
+	// params, shared by the methods taking the same struct
+	JSONVar string
+}
+
+// GenBind is the code of a handler filling params from r.Form and checking
+// them field by field. With AllErrors every field is checked in a func of
+// its own, returning at its first error, and the errors are answered
+// together after the last field.
+func (def *StructDef) GenBind() string {
+	checks := &bytes.Buffer{}
+
+	for _, f := range def.Fields {
+		fieldChecks := f.genChecks(def.AllErrors)
+
+		switch {
+		case !def.AllErrors || fieldChecks == "":
+			checks.WriteString(fieldChecks)
+		case f.parsed():
+			f.invalidUsed = true
+			fmt.Fprintf(checks, "if !%s {\nfunc() {\n%s}()\n}\n", f.invalidVar(), fieldChecks)
+		default:
+			fmt.Fprintf(checks, "func() {\n%s}()\n", fieldChecks)
+		}
+	}
+
+	w := &bytes.Buffer{}
+
+	for _, alloc := range def.Allocs {
+		fmt.Fprintln(w, alloc)
+	}
+
+	if def.AllErrors {
+		fmt.Fprintln(w, "var errs []FieldError")
+	}
+
+	for _, f := range def.Fields {
+		if f.invalidUsed {
+			fmt.Fprintf(w, "var %s bool\n", f.invalidVar())
+		}
+
+		if f.sentUsed {
+			fmt.Fprintf(w, "var %s bool\n", f.sentVar())
+		}
+	}
+
+	fmt.Fprintln(w)
+
+	for _, f := range def.Fields {
+		fmt.Fprintln(w, f.genParamAssign(def.AllErrors))
+	}
+
+	fmt.Fprintln(w)
+	w.Write(checks.Bytes())
+
+	if def.AllErrors {
+		fmt.Fprint(w, "\nif len(errs) > 0 {\nhandleFieldErrors(w, errs)\n\nreturn\n}\n")
+	}
+
+	return w.String()
+}
+
+// GENERATE PART
+
+// formValue is the first value of the param key, a nested param may be spelled
+// with brackets as well: filter.status or filter[status]. The value is
+// normalized first, then an empty one is missing unless keepEmpty, which
+// pointer fields set to tell an empty value from a missing one, and def, if
+// any, stands for a missing value
+func formValue(values url.Values, key, bracketKey, def string, keepEmpty bool, normalize ...string) (string, bool) {
+	vs, ok := values[key]
+	if !ok && bracketKey != "" {
+		vs, ok = values[bracketKey]
+	}
+
+	if ok && len(vs) > 0 {
+		if sv := normalizeValue(vs[0], normalize); sv != "" || keepEmpty {
+			return sv, true
+		}
+	}
+
+	return def, def != ""
+}
+
+// formItems lists the items of the repeated param key, each value may hold
+// several items separated by split; the items are normalized, empty ones
+// are dropped and def, if any, stands for a missing param
+func formItems(values url.Values, key, bracketKey, split, def string, normalize ...string) []string {
+	raw := values[key]
+	if bracketKey != "" {
+		raw = append(raw[:len(raw):len(raw)], values[bracketKey]...)
+	}
+
+	if len(raw) == 0 && def != "" {
+		raw = []string{def}
+	}
+
+	var items []string
+
+	for _, sv := range raw {
+		if split == "" {
+			if sv = normalizeValue(sv, normalize); sv != "" {
+				items = append(items, sv)
+			}
+
+			continue
+		}
+
+		for _, item := range strings.Split(sv, split) {
+			if item = normalizeValue(item, normalize); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// normalizeValue applies the normalization options of apivalidator to a
+// param value in the order they are given: trim, collapse, lower, upper
+func normalizeValue(s string, options []string) string {
+	for _, option := range options {
+		switch option {
+		case "trim":
+			s = strings.TrimSpace(s)
+		case "collapse":
+			s = collapseSpaces(s)
+		case "lower":
+			s = strings.ToLower(s)
+		case "upper":
+			s = strings.ToUpper(s)
+		}
+	}
+
+	return s
+}
+
+// collapseSpaces replaces every run of white space in s with a single space
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	space := false
+
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+
+			space = true
+
+			continue
+		}
+
+		space = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// jsonField is a key of a JSON body bound to the param key, kind is the
+// JSON type of its values: string, number, boolean or object for a nested
+// struct, and slice tells that the values come in an array
+type jsonField struct {
+	key   string
+	kind  string
+	slice bool
+}
+
+// maxJSONBody limits the size of a JSON body
+const maxJSONBody = 1 << 20
+
+// parseJSONForm reads a JSON object body into r.Form, so that the params are
+// bound the same way as the form ones: the values of fields go to their
+// param keys, nested objects hold the params of nested structs and arrays
+// the items of slices. Other content types are left to r.ParseForm
+func parseJSONForm(w http.ResponseWriter, r *http.Request, fields map[string]jsonField) error {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	dec.UseNumber()
+
+	var body interface{}
+	if err := dec.Decode(&body); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid JSON body: unexpected data after the object")
+	}
+
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		return errors.New("JSON body must be an object")
+	}
+
+	if r.Form == nil {
+		r.Form = url.Values{}
+	}
+
+	return bindJSONObject(r.Form, fields, "", object)
+}
+
+// bindJSONObject sets the form values from the fields of object, prefix is
+// the path of the object in the body. A null is a missing value
+func bindJSONObject(form url.Values, fields map[string]jsonField, prefix string, object map[string]interface{}) error {
+	// in order, so that the same body gets the same error
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		value := object[name]
+
+		field, ok := fields[path]
+		if !ok {
+			return fmt.Errorf("unknown field %s", path)
+		}
+
+		if value == nil {
+			continue
+		}
+
+		switch {
+		case field.kind == "object":
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be an object", path)
+			}
+
+			if err := bindJSONObject(form, fields, path+".", nested); err != nil {
+				return err
+			}
+		case field.slice:
+			items, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be an array", path)
+			}
+
+			values := make([]string, 0, len(items))
+
+			for _, item := range items {
+				sv, ok := jsonScalar(item, field.kind)
+				if !ok {
+					return fmt.Errorf("%s must be an array of %ss", path, field.kind)
+				}
+
+				values = append(values, sv)
+			}
+
+			form[field.key] = values
+		default:
+			sv, ok := jsonScalar(value, field.kind)
+			if !ok {
+				return fmt.Errorf("%s must be a %s", path, field.kind)
+			}
+
+			form[field.key] = []string{sv}
+		}
+	}
+
+	return nil
+}
+
+// jsonScalar is the form value of a decoded JSON value of the kind
+func jsonScalar(value interface{}, kind string) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, kind == "string"
+	case json.Number:
+		return v.String(), kind == "number"
+	case bool:
+		return strconv.FormatBool(v), kind == "boolean"
+	}
+
+	return "", false
+}
+
+// parseMultipartForm reads a multipart/form-data body into r.Form and the
+// files into r.MultipartForm, keeping up to maxMemory bytes of them in
+// memory and the rest in temporary files. A body over maxBody is cut off
+// with an *http.MaxBytesError. Other content types are left to r.ParseForm
+func parseMultipartForm(w http.ResponseWriter, r *http.Request, maxMemory, maxBody int64) error {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+
+	if !strings.EqualFold(strings.TrimSpace(mediaType), "multipart/form-data") {
+		return nil
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return fmt.Errorf("invalid multipart body: %w", err)
+	}
+
+	return nil
+}
+
+// formFiles lists the files uploaded as the param key, spelled with brackets
+// as well for a nested param
+func formFiles(r *http.Request, key, bracketKey string) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+
+	files := r.MultipartForm.File[key]
+	if bracketKey != "" {
+		files = append(files[:len(files):len(files)], r.MultipartForm.File[bracketKey]...)
+	}
+
+	return files
+}
+
+// formFile is the first file uploaded as the param key, nil if there is none
+func formFile(r *http.Request, key, bracketKey string) *multipart.FileHeader {
+	if files := formFiles(r, key, bracketKey); len(files) > 0 {
+		return files[0]
+	}
+
+	return nil
+}
+
+// validContentType tells whether the content type the client sent for the
+// file is one of allowed, image/* allows any image
+func validContentType(file *multipart.FileHeader, allowed ...string) bool {
+	mediaType, _, _ := strings.Cut(file.Header.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, t := range allowed {
+		if t == mediaType || strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPath matches the escaped path against the url of a method, whose
+// {name} segments match any non-empty segment; the values of the segments
+// are unescaped and keyed by name
+func matchPath(pattern, path string) (url.Values, bool) {
+	patternSegments, segments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(patternSegments) != len(segments) {
+		return nil, false
+	}
+
+	values := url.Values{}
+
+	for i, p := range patternSegments {
+		if !strings.HasPrefix(p, "{") {
+			if p != segments[i] {
+				return nil, false
+			}
+
+			continue
+		}
+
+		v, err := url.PathUnescape(segments[i])
+		if err != nil || v == "" {
+			return nil, false
+		}
+
+		values.Set(p[1:len(p)-1], v)
+	}
+
+	return values, true
+}
+
+// bindPathParams sets the params of the {name} segments of the url in
+// r.Form, a path value overrides the query and body ones of the same name
+func bindPathParams(r *http.Request, pattern string) {
+	values, _ := matchPath(pattern, r.URL.EscapedPath())
+
+	if r.Form == nil {
+		r.Form = url.Values{}
+	}
+
+	for name, vs := range values {
+		r.Form[name] = vs
+	}
+}
+
+// parseFloat is strconv.ParseFloat rejecting NaN and the infinities, which
+// would pass the required, min and max checks and can't be written as JSON
+func parseFloat(s string, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return 0, strconv.ErrSyntax
+	}
+
+	return f, err
+}
+
+// parseError is the message about a param value strconv failed to parse as
+// typeName
+func parseError(paramName, typeName string, err error) string {
+	if errors.Is(err, strconv.ErrRange) {
+		return paramName + " is out of " + typeName + " range"
+	}
+
+	return paramName + " must be " + typeName
+}
+
+// validFormat tells whether s is written in the format named by the format
+// option of apivalidator
+func validFormat(format, s string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+
+		return err == nil && addr.Address == s
+	case "uuid":
+		if len(s) != 36 {
+			return false
+		}
+
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+
+			switch {
+			case i == 8 || i == 13 || i == 18 || i == 23:
+				if c != '-' {
+					return false
+				}
+			case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+			default:
+				return false
+			}
+		}
+
+		return true
+	case "url":
+		u, err := url.ParseRequestURI(s)
+
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case "ip":
+		return net.ParseIP(s) != nil
+	case "hostname":
+		if len(s) == 0 || len(s) > 253 {
+			return false
+		}
+
+		for _, label := range strings.Split(s, ".") {
+			if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+				return false
+			}
+
+			for i := 0; i < len(label); i++ {
+				c := label[i]
+				if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-') {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// EOF GENERATE PART
+
+func (def *FieldDef) String() string {
+	return fmt.Sprintf("%s %s %s\n", def.Name, def.TypeName, def.Tag)
+}
+
+func inspectFuncSignature(pkg *Package, fd *ast.FuncDecl, funcCall *FuncDef, imports *Imports) error {
+	funcCall.MethodName = fd.Name.Name
+	funcCall.CommentText = fd.Doc.Text()
+	funcCall.ApiArgs = &ApiGenArgs{}
+
+	fn, ok := pkg.Info.Defs[fd.Name].(*types.Func)
+	if !ok {
+		return errorAt(fd.Name.Pos(), "%s: not type-checked", fd.Name.Name)
+	}
+
+	sig := fn.Type().(*types.Signature)
+
+	// receiver, T or *T, possibly generic
+	recv, _ := derefType(sig.Recv().Type())
+	named, ok := recv.(*types.Named)
+	if !ok {
+		return errorAt(fd.Recv.Pos(), "%s: unsupported receiver %s", fd.Name.Name, recv)
+	}
+
+	funcCall.ReceiverName = named.Obj().Name()
+	funcCall.ReceiverType = funcCall.ReceiverName
+
+	if tparams := sig.RecvTypeParams(); tparams.Len() > 0 {
+		var names []string
+		for i := 0; i < tparams.Len(); i++ {
+			names = append(names, tparams.At(i).Obj().Name())
+		}
+
+		funcCall.ReceiverType += "[" + strings.Join(names, ", ") + "]"
+	}
+
+	method := funcCall.ReceiverName + "." + fd.Name.Name
+
+	// the annotation is checked along with the signature, both are reported
+	var annotationErr error
+	if err := funcCall.ApiArgs.Parse(funcCall.CommentText); err != nil {
+		pos := fd.Pos()
+		if fd.Doc != nil {
+			pos = fd.Doc.Pos()
+		}
+
+		annotationErr = errorAt(pos, "%s: bad apigen:api annotation: %v", method, err)
+	}
+
+	unsupported := func(why string) error {
+		return errors.Join(annotationErr, errorAt(fd.Name.Pos(), "%s: %s; the signature must be func([ctx context.Context,] [in Params]) ([Result,] error)", method, why))
+	}
+
+	// params: an optional context followed by an optional parameters struct
+	params := sig.Params()
+	if sig.Variadic() {
+		return unsupported("variadic parameters")
+	}
+
+	first := 0
+	if params.Len() > 0 && isContext(params.At(0).Type()) {
+		funcCall.Context = true
+		first = 1
+	}
+
+	switch params.Len() - first {
+	case 0:
+	case 1:
+		param := params.At(first)
+		paramType, isPointer := derefType(param.Type())
+
+		st, ok := paramType.Underlying().(*types.Struct)
+		if !ok {
+			return unsupported(fmt.Sprintf("parameter %s is %s, not a struct", param.Name(), param.Type()))
+		}
+
+		funcCall.ArgumentName = param.Name()
+		funcCall.ArgumentTypeName = types.TypeString(paramType, imports.Qualifier)
+		funcCall.ArgumentPointer = isPointer
+
+		argumentStruct, err := newStructDef(funcCall.ArgumentTypeName, st, pkg.Types, imports)
+		if err != nil {
+			return errors.Join(annotationErr, err)
+		}
+
+		funcCall.ArgumentStruct = argumentStruct
+
+		if err := inspectValidate(paramType, funcCall); err != nil {
+			return errors.Join(annotationErr, err)
+		}
+	default:
+		return unsupported(fmt.Sprintf("%d parameters", params.Len()))
+	}
+
+	// results: an optional response and an error
+	results := sig.Results()
+	if results.Len() == 0 || results.Len() > 2 || !isError(results.At(results.Len()-1).Type()) {
+		return unsupported("the last result must be error")
+	}
+
+	if results.Len() == 2 {
+		resultType, _ := derefType(results.At(0).Type())
+		funcCall.ResulTypeName = types.TypeString(resultType, imports.Qualifier)
+	}
+
+	if annotationErr != nil {
+		return annotationErr
+	}
+
+	return inspectPlaceholders(fd, funcCall, method)
+}
+
+// inspectPlaceholders checks that every placeholder of the url names a
+// param of the parameters struct, which is then bound from the path like a
+// query param. Files and nested params can't come from a path segment.
+func inspectPlaceholders(fd *ast.FuncDecl, funcCall *FuncDef, method string) error {
+	var errs []error
+
+	for _, name := range funcCall.ApiArgs.Placeholders() {
+		var field *FieldDef
+		for _, f := range funcCall.Fields() {
+			if f.Prefix == "" && f.ParamName() == name {
+				field = f
+			}
+		}
+
+		switch {
+		case field == nil:
+			errs = append(errs, errorAt(fd.Name.Pos(), "%s: url placeholder {%s} has no matching field", method, name))
+		case field.File:
+			errs = append(errs, errorAt(field.Pos, "%s.%s: url placeholder {%s} can't be bound to a file", funcCall.ArgumentTypeName, field.Path, name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// inspectValidate looks for the Validate hook of the parameters struct,
+// func() error or func(context.Context) error, promoted ones included.
+func inspectValidate(paramType types.Type, funcCall *FuncDef) error {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(paramType), true, nil, "Validate")
+
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+
+	sig := fn.Type().(*types.Signature)
+	results := sig.Results()
+
+	if results.Len() == 1 && isError(results.At(0).Type()) && !sig.Variadic() {
+		switch {
+		case sig.Params().Len() == 0:
+			funcCall.ValidateCall = "params.Validate()"
+
+			return nil
+		case sig.Params().Len() == 1 && isContext(sig.Params().At(0).Type()):
+			funcCall.ValidateCall = "params.Validate(r.Context())"
+
+			return nil
+		}
+	}
+
+	return errorAt(fn.Pos(), "%s.Validate: the hook must be func() error or func(ctx context.Context) error", funcCall.ArgumentTypeName)
+}
+
+// Fields are the bound fields of the parameters struct, if any.
+func (p *FuncDef) Fields() []*FieldDef {
+	if p.ArgumentStruct == nil {
+		return nil
+	}
+
+	return p.ArgumentStruct.Fields
+}
+
+// CallArgs are the arguments the handler passes to the method.
+func (p *FuncDef) CallArgs() string {
+	var args []string
+
+	if p.Context {
+		args = append(args, "r.Context()")
+	}
+
+	if p.ArgumentStruct != nil {
+		args = append(args, "params")
+	}
+
+	return strings.Join(args, ", ")
+}
+
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+//func populateStructs(s []*StructDef, f []*FuncDef) *StructDef {
+//	for _, v := range s {
+//		if v.Name == name {
+//			return v
+//		}
 //	}
 //
 //	return nil
 //}
 
-func main() {
-	inFileName := os.Args[1]
-	outFileName := os.Args[2]
+// collectFuncs gathers annotated methods declared in the files of pkg,
+// the problems of the methods go to diags.
+func collectFuncs(pkg *Package, imports *Imports, diags *Diagnostics) []*FuncDef {
+	var funcCalls []*FuncDef
 
-	fileSet := token.NewFileSet()
+	for _, file := range pkg.Files {
+		for _, d := range file.Decls {
+			f, ok := d.(*ast.FuncDecl)
+			if !ok || f.Recv == nil || !strings.Contains(f.Doc.Text(), annotationMarker) {
+				continue
+			}
 
-	node, err := parser.ParseFile(fileSet, inFileName, nil, parser.ParseComments)
+			var funcCall = &FuncDef{}
 
-	if err != nil {
-		log.Fatal(err)
+			if err := inspectFuncSignature(pkg, f, funcCall, imports); err != nil {
+				diags.Add(f.Name.Pos(), err)
+
+				continue
+			}
+
+			funcCalls = append(funcCalls, funcCall)
+		}
 	}
 
-	outFile, _ := os.Create(outFileName)
+	return funcCalls
+}
 
-	fmt.Fprintln(outFile, `package `+node.Name.Name)
-	fmt.Fprintln(outFile)                                               // empty line
-	fmt.Fprintln(outFile, `import "encoding/json"`)                     // empty line
-	fmt.Fprintln(outFile, `import "fmt"`)                               // empty line
-	fmt.Fprintln(outFile, `import "github.com/asaskevich/govalidator"`) // empty line
-	fmt.Fprintln(outFile, `import "net/http"`)                          // empty line
-	fmt.Fprintln(outFile, `import "net/url"`)                           // empty line
-	fmt.Fprintln(outFile, `import "strconv"`)                           // empty line
-	fmt.Fprintln(outFile, `import "strings"`)                           // empty line
-	fmt.Fprintln(outFile)
+// genOptions are set by the flags of the generator for every method of the
+// packages, the annotation of a method may override them.
+type genOptions struct {
+	// every handler reports all the invalid params, not only the ones
+	// annotated with all_errors
+	AllErrors bool
+	// bytes of a multipart body kept in memory unless the method sets
+	// multipart_memory, 0 is defaultMultipartMemory
+	MultipartMemory int64
+	// bytes of a multipart body read at most unless the method sets
+	// max_body, 0 is the one of defaultMaxBody
+	MaxBody int64
+}
 
-	var funcCalls []*FuncDef
-	var structs []*StructDef
+// defaultMultipartMemory is the one of http.Request.FormFile.
+const defaultMultipartMemory = 32 << 20
+
+// defaultMaxFile stands for the maxsize of the files in defaultMaxBody when
+// none of them has one.
+const defaultMaxFile = 32 << 20
+
+// defaultMaxBody is the largest maxsize of the file fields of the method,
+// defaultMaxFile without one, plus the bytes kept in memory, so that a file
+// over its maxsize is still read and answered with its own message.
+func defaultMaxBody(fc *FuncDef) int64 {
+	var maxFile int64
+	for _, f := range fc.Fields() {
+		if f.File && f.ValidatorMeta.MaxSize > maxFile {
+			maxFile = f.ValidatorMeta.MaxSize
+		}
+	}
 
-	// BadDecl | FuncDecl | GenDecl
-	for _, d := range node.Decls {
-		switch d.(type) {
-		case *ast.BadDecl:
-		case *ast.FuncDecl:
-			f, _ := d.(*ast.FuncDecl)
+	if maxFile == 0 {
+		maxFile = defaultMaxFile
+	}
 
-			var funcCall = &FuncDef{}
+	return maxFile + fc.MultipartMemory
+}
+
+// generatePackage renders the handlers of every annotated method of pkg.
+// It returns nil when the package has nothing to generate or has problems,
+// which are added to diags.
+func generatePackage(pkg *Package, diags *Diagnostics, opts genOptions) []byte {
+	imports := NewImports(pkg.Types, "encoding/json", "errors", "fmt", "io", "math", "mime/multipart", "net", "net/http", "net/mail", "net/url", "sort", "strconv", "strings", "unicode")
+
+	before := diags.Len()
+	funcCalls := collectFuncs(pkg, imports, diags)
+
+	if len(funcCalls) == 0 || diags.Len() > before {
+		return nil
+	}
 
-			if f.Recv != nil && strings.Contains(f.Doc.Text(), "apigen:api") {
-				fmt.Println("M")
+	for _, fc := range funcCalls {
+		if fc.ArgumentStruct != nil {
+			fc.ArgumentStruct.AllErrors = fc.ApiArgs.AllErrors || opts.AllErrors
+		}
+
+		switch {
+		case fc.ApiArgs.MultipartMemory > 0:
+			fc.MultipartMemory = fc.ApiArgs.MultipartMemory
+		case opts.MultipartMemory > 0:
+			fc.MultipartMemory = opts.MultipartMemory
+		default:
+			fc.MultipartMemory = defaultMultipartMemory
+		}
 
-				inspectFuncSignature(f, funcCall)
+		switch {
+		case fc.ApiArgs.MaxBody > 0:
+			fc.MaxBody = fc.ApiArgs.MaxBody
+		case opts.MaxBody > 0:
+			fc.MaxBody = opts.MaxBody
+		default:
+			fc.MaxBody = defaultMaxBody(fc)
+		}
 
-				funcCalls = append(funcCalls, funcCall)
+		// the dates and durations are parsed and checked by package time
+		for _, f := range fc.Fields() {
+			if strings.HasPrefix(f.TypeName, "time.") {
+				imports.Add("time", "time")
 			}
-		case *ast.GenDecl:
-			fmt.Println("G")
+		}
+	}
 
-			g, _ := d.(*ast.GenDecl)
+	patterns := compilePatterns(funcCalls, imports)
+	jsonFields := compileJSONFields(funcCalls)
+	grouped := groupFunctionsByReceiver(funcCalls)
 
-		SPECS_LOOP:
-			// *ImportSpec | *ValueSpec | *TypeSpec
-			for _, spec := range g.Specs {
-				currType, ok := spec.(*ast.TypeSpec)
-				if !ok {
-					continue
-				}
+	var receivers []string
+	for k := range grouped {
+		receivers = append(receivers, k)
+	}
 
-				currStruct, ok := currType.Type.(*ast.StructType)
-				if !ok {
-					continue
-				}
+	sort.Strings(receivers)
 
-				struc := &StructDef{Name: currType.Name.Name}
-			FIELDS_LOOP:
-				for i, field := range currStruct.Fields.List {
-					if field.Tag == nil {
-						continue SPECS_LOOP
-					}
+	out := bytes.NewBufferString("")
 
-					tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
-					apiValidatorTag := tag.Get("apivalidator")
-					jsonTag := tag.Get("json")
-					empty := ""
-					apiValidatorTagNotEmpty := apiValidatorTag != empty
-					jsonTagNotEmpty := jsonTag != empty
+	fmt.Fprintln(out, `// Code generated by handlers_gen. DO NOT EDIT.`)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, `package `+pkg.Name)
+	fmt.Fprintln(out)
+	imports.Write(out)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, patterns)
+	fmt.Fprintln(out, jsonFields)
 
-					if !apiValidatorTagNotEmpty && !jsonTagNotEmpty {
-						continue FIELDS_LOOP
-					}
+	for _, k := range receivers {
+		fmt.Fprintln(out, genServeHTTP(grouped[k][0].ReceiverType, grouped[k]))
+	}
+
+	for _, k := range receivers {
+		fmt.Fprintln(out, genHandlers(k, grouped[k]))
+	}
 
-					fieldName := field.Names[0].Name
-					fieldType := field.Type.(*ast.Ident).Name
+	fmt.Fprintln(out, genByTemplate("middleware.template", nil))
 
-					switch fieldType {
-					case "int":
-					case "uint64":
-					case "string":
-					default:
-						log.Fatalln("unsupported", fieldType)
-					}
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		// a bug of the generator rather than of the package
+		diags.AddFile(pkg.Output, fmt.Errorf("gofmt of generated code failed: %v", err))
 
-					field := &FieldDef{
-						Name:          fieldName,
-						TypeName:      fieldType,
-						Tag:           tag,
-						ValidatorMeta: &FieldValidator{},
-					}
+		return nil
+	}
 
-					stag := string(field.Tag)
+	return src
+}
 
-					if err := field.ValidatorMeta.Parse(stag); err != nil {
-						log.Fatalln("ParsedValidator.Parse failed for tag " + stag)
-					}
+// compileJSONFields declares the JSON keys of the params structs for
+// parseJSONForm, one table per struct type, and sets JSONVar of the structs.
+func compileJSONFields(funcCalls []*FuncDef) string {
+	vars := map[string]string{}
+	w := &bytes.Buffer{}
+
+	for _, fc := range funcCalls {
+		if fc.ArgumentStruct == nil {
+			continue
+		}
+
+		name, ok := vars[fc.ArgumentTypeName]
+		if !ok {
+			name = fmt.Sprintf("paramJSON%d", len(vars)+1)
+			vars[fc.ArgumentTypeName] = name
+			objects := map[string]bool{}
 
-					struc.Fields = append(struc.Fields, field)
+			fmt.Fprintf(w, "var %s = map[string]jsonField{\n", name)
 
-					if i == len(currStruct.Fields.List)-1 {
-						structs = append(structs, struc)
+			for _, f := range fc.ArgumentStruct.Fields {
+				// files come only in multipart bodies, json:"-" fields
+				// never come in JSON
+				if f.File || f.JSONName == "" {
+					continue
+				}
+
+				// the objects of nested structs on the way to the field
+				for i := range f.JSONName {
+					if object := f.JSONName[:i]; f.JSONName[i] == '.' && !objects[object] {
+						objects[object] = true
+						fmt.Fprintf(w, "%q: {kind: \"object\"},\n", object)
 					}
 				}
+
+				slice := ""
+				if f.Slice {
+					slice = ", slice: true"
+				}
+
+				fmt.Fprintf(w, "%q: {key: %q, kind: %q%s},\n", f.JSONName, f.ParamName(), f.jsonKind(), slice)
 			}
+
+			fmt.Fprint(w, "}\n\n")
 		}
+
+		fc.ArgumentStruct.JSONVar = name
 	}
 
-	associateFuncArgumentStruct(funcCalls, structs)
+	return w.String()
+}
 
-	//fmt.Println("METHODS")
-	//for _, fc := range funcCalls {
-	//	fmt.Printf("%+v\n", fc)
-	//}
-	//
-	//fmt.Println()
-	//
-	//fmt.Println("STRUCTS")
-	//for _, x := range structs {
-	//	fmt.Printf("%+v\n", x)
-	//}
+// compilePatterns declares a package variable for every distinct pattern of
+// the fields, so that the regexps are compiled once rather than per request,
+// and points the fields to them.
+func compilePatterns(funcCalls []*FuncDef, imports *Imports) string {
+	vars := map[string]string{}
+	w := &bytes.Buffer{}
+
+	for _, fc := range funcCalls {
+		for _, f := range fc.Fields() {
+			pattern := f.ValidatorMeta.Pattern
+			if pattern == "" {
+				continue
+			}
 
-	grouped := groupFunctionsByReceiver(funcCalls)
+			name, ok := vars[pattern]
+			if !ok {
+				name = fmt.Sprintf("paramPattern%d", len(vars)+1)
+				vars[pattern] = name
+				lit := strconv.Quote(pattern)
+				if strconv.CanBackquote(pattern) {
+					lit = "`" + pattern + "`"
+				}
+
+				fmt.Fprintf(w, "var %s = %s.MustCompile(%s)\n", name, imports.Add("regexp", "regexp"), lit)
+			}
+
+			f.PatternVar = name
+		}
+	}
+
+	return w.String()
+}
+
+const defaultOutput = "api_handlers.go"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: codegen [-o file] [-all-errors] [-multipart-memory bytes] [-max-body bytes] [packages]
+       codegen input.go output.go
+
+packages are directories, "dir/..." patterns or .go files standing for the
+package they belong to; the default is the current directory. One file named
+by -o is written into every package that has apigen:api methods.
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	output := flag.String("o", defaultOutput, "name of the generated file inside each package directory")
+	var opts genOptions
+	flag.BoolVar(&opts.AllErrors, "all-errors", false, "report all the invalid params of every handler instead of the first one")
+	flag.Int64Var(&opts.MultipartMemory, "multipart-memory", defaultMultipartMemory, "bytes of a multipart body kept in memory, the rest of the files go to temporary files")
+	flag.Int64Var(&opts.MaxBody, "max-body", 0, "bytes of a multipart body read at most, 0 is the largest maxsize of the files plus the memory limit")
+	flag.Usage = usage
+	flag.Parse()
+
+	type job struct {
+		dir    string
+		output string
+	}
+
+	var jobs []job
+	args := flag.Args()
+
+	if len(args) == 2 && strings.HasSuffix(args[0], ".go") && strings.HasSuffix(args[1], ".go") {
+		// the original form: codegen api.go api_handlers.go
+		jobs = append(jobs, job{dir: filepath.Dir(args[0]), output: args[1]})
+	} else {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		dirs, err := expandPatterns(args)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	for k, v := range grouped {
-		fmt.Fprintln(outFile, genServeHTTP(k, v))
+		for _, dir := range dirs {
+			jobs = append(jobs, job{dir: dir, output: filepath.Join(dir, *output)})
+		}
 	}
 
-	for k, v := range grouped {
-		fmt.Fprintln(outFile, genHandlers(k, v))
+	fileSet := token.NewFileSet()
+	imp := importer.ForCompiler(fileSet, "source", nil)
+	diags := NewDiagnostics(fileSet)
+
+	for _, j := range jobs {
+		pkg, err := loadPackage(fileSet, imp, j.dir, j.output)
+		if err != nil {
+			diags.Add(token.NoPos, err)
+
+			continue
+		}
+
+		if pkg == nil {
+			continue
+		}
+
+		src := generatePackage(pkg, diags, opts)
+		if src == nil {
+			continue
+		}
+
+		if err := os.WriteFile(pkg.Output, src, 0644); err != nil {
+			diags.AddFile(pkg.Output, err)
+		}
 	}
 
-	fmt.Fprintln(outFile, genByTemplate("middleware.template", nil))
+	// go generate fails loudly on any of them
+	if diags.Len() > 0 {
+		diags.Write(os.Stderr)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"sort"
+)
+
+// posError is an error about the code at pos: a field, a method or its
+// annotation. Its message doesn't include the position, Diagnostics add it.
+type posError struct {
+	pos token.Pos
+	err error
+}
+
+func errorAt(pos token.Pos, format string, args ...interface{}) error {
+	return &posError{pos: pos, err: fmt.Errorf(format, args...)}
+}
+
+func (e *posError) Error() string {
+	return e.err.Error()
+}
+
+func (e *posError) Unwrap() error {
+	return e.err
+}
+
+// Diagnostic is one problem of the generated packages, it reads as
+// file:line:col: message.
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if !d.Pos.IsValid() && d.Pos.Filename == "" {
+		return d.Message
+	}
+
+	return d.Pos.String() + ": " + d.Message
+}
+
+// Diagnostics collects the problems of the whole run, so that all of them
+// are reported at once instead of stopping at the first one.
+type Diagnostics struct {
+	fileSet *token.FileSet
+	list    []Diagnostic
+}
+
+func NewDiagnostics(fileSet *token.FileSet) *Diagnostics {
+	return &Diagnostics{fileSet: fileSet}
+}
+
+// Add records err, which may be a joined list of errors. Errors without a
+// position of their own get pos, which may be token.NoPos.
+func (d *Diagnostics) Add(pos token.Pos, err error) {
+	switch e := err.(type) {
+	case nil:
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			d.Add(pos, err)
+		}
+	case *posError:
+		d.Add(e.pos, e.err)
+	case scanner.ErrorList:
+		for _, se := range e {
+			d.add(Diagnostic{Pos: se.Pos, Message: se.Msg})
+		}
+	default:
+		d.add(Diagnostic{Pos: d.fileSet.Position(pos), Message: err.Error()})
+	}
+}
+
+// AddFile records err about a file as a whole, like a failed write.
+func (d *Diagnostics) AddFile(filename string, err error) {
+	d.add(Diagnostic{Pos: token.Position{Filename: filename}, Message: err.Error()})
+}
+
+// add records diag once: a parameters struct shared by several methods is
+// checked for each of them.
+func (d *Diagnostics) add(diag Diagnostic) {
+	for _, other := range d.list {
+		if other == diag {
+			return
+		}
+	}
+
+	d.list = append(d.list, diag)
+}
+
+func (d *Diagnostics) Len() int {
+	return len(d.list)
+}
+
+// Write prints the diagnostics ordered by position.
+func (d *Diagnostics) Write(w io.Writer) {
+	sort.SliceStable(d.list, func(i, j int) bool {
+		a, b := d.list[i].Pos, d.list[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+
+	for _, diag := range d.list {
+		fmt.Fprintln(w, diag)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"go/importer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsCollected(t *testing.T) {
+	dir := t.TempDir()
+
+	src := `package api
+
+import "context"
+
+type Params struct {
+	Age  int     ` + "`apivalidator:\"min=x\"`" + `
+	Rate float64 ` + "`apivalidator:\"split=,\"`" + `
+	Ok   string  ` + "`apivalidator:\"required\"`" + `
+	Tags []int   ` + "`apivalidator:\"split=,,default='1,x'\"`" + `
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/b",}
+func (a *Api) Bad(ctx context.Context, id int) error { return nil }
+
+// apigen:api {"url": "/c"}
+func (a *Api) Good() error { return nil }
+
+// the problems of Params are reported once
+// apigen:api {"url": "/d"}
+func (a *Api) Again(in Params) error { return nil }
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "api.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileSet := token.NewFileSet()
+
+	pkg, err := loadPackage(fileSet, importer.ForCompiler(fileSet, "source", nil), dir, filepath.Join(dir, "api_handlers.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := NewDiagnostics(fileSet)
+	if src := generatePackage(pkg, diags, genOptions{}); src != nil {
+		t.Error("nothing expected to be generated for a package with problems")
+	}
+
+	out := &bytes.Buffer{}
+	diags.Write(out)
+
+	file := filepath.Join(dir, "api.go")
+	expected := []string{
+		file + `:6:2: Params.Age: "x" is not a valid int`,
+		file + ":7:2: Params.Rate: minitems, maxitems and split apply to slices only",
+		file + `:9:2: Params.Tags: default "x" is not a valid int`,
+		file + ":17:1: Api.Bad: bad apigen:api annotation: ",
+		file + ":18:15: Api.Bad: parameter id is int, not a struct; ",
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("%d diagnostics expected, got\n%s", len(expected), out)
+	}
+
+	for i, prefix := range expected {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("%q expected to start with %q", lines[i], prefix)
+		}
+	}
+}
+
+func TestUrlPlaceholders(t *testing.T) {
+	dir := t.TempDir()
+
+	src := `package api
+
+import "mime/multipart"
+
+type Params struct {
+	Login  string                ` + "`apivalidator:\"required\"`" + `
+	ID     int                   ` + "`apivalidator:\"paramname=user_id\"`" + `
+	Avatar *multipart.FileHeader ` + "`apivalidator:\"required\"`" + `
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/user/{login}/items/{user_id}"}
+func (a *Api) Good(in Params) error { return nil }
+
+// apigen:api {"url": "/user/{name}"}
+func (a *Api) Unknown(in Params) error { return nil }
+
+// apigen:api {"url": "/user/{login}"}
+func (a *Api) NoParams() error { return nil }
+
+// apigen:api {"url": "/user/{avatar}"}
+func (a *Api) File(in Params) error { return nil }
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "api.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileSet := token.NewFileSet()
+
+	pkg, err := loadPackage(fileSet, importer.ForCompiler(fileSet, "source", nil), dir, filepath.Join(dir, "api_handlers.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := NewDiagnostics(fileSet)
+	generatePackage(pkg, diags, genOptions{})
+
+	out := &bytes.Buffer{}
+	diags.Write(out)
+
+	file := filepath.Join(dir, "api.go")
+	expected := []string{
+		file + ":8:2: Params.Avatar: url placeholder {avatar} can't be bound to a file",
+		file + ":17:15: Api.Unknown: url placeholder {name} has no matching field",
+		file + ":20:15: Api.NoParams: url placeholder {login} has no matching field",
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("%d diagnostics expected, got\n%s", len(expected), out)
+	}
+
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("%q expected, got %q", line, lines[i])
+		}
+	}
+}
+
+func TestUndefinedFieldTypes(t *testing.T) {
+	dir := t.TempDir()
+
+	src := `package api
+
+type Alias Missing
+
+type Params struct {
+	X Undefined  ` + "`apivalidator:\"required\"`" + `
+	Y []*Missing ` + "`apivalidator:\"paramname=y\"`" + `
+	Z Alias      ` + "`apivalidator:\"paramname=z\"`" + `
+	Ok string    ` + "`apivalidator:\"required\"`" + `
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(in Params) error { return nil }
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "api.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileSet := token.NewFileSet()
+
+	pkg, err := loadPackage(fileSet, importer.ForCompiler(fileSet, "source", nil), dir, filepath.Join(dir, "api_handlers.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := NewDiagnostics(fileSet)
+	if src := generatePackage(pkg, diags, genOptions{}); src != nil {
+		t.Error("nothing expected to be generated for a package with undefined types")
+	}
+
+	out := &bytes.Buffer{}
+	diags.Write(out)
+
+	file := filepath.Join(dir, "api.go")
+	expected := []string{
+		file + ":6:2: Params.X: the type is undefined or doesn't compile",
+		file + ":7:2: Params.Y: the type is undefined or doesn't compile",
+		file + ":8:2: Params.Z: the type is undefined or doesn't compile",
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("%d diagnostics expected, got\n%s", len(expected), out)
+	}
+
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("%q expected, got %q", line, lines[i])
+		}
+	}
+}
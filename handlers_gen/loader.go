@@ -0,0 +1,139 @@
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is one Go package loaded from disk: every non-test file of the
+// directory except the file we are about to (re)generate, type-checked.
+type Package struct {
+	Name   string
+	Dir    string
+	Output string
+	Files  []*ast.File
+	Types  *types.Package
+	Info   *types.Info
+}
+
+// expandPatterns turns command line arguments into package directories.
+// Accepted forms are a directory, a directory followed by "/..." and a .go
+// file, which stands for the package the file belongs to.
+func expandPatterns(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var dirs []string
+
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, p := range patterns {
+		switch {
+		case strings.HasSuffix(p, "/..."):
+			root := strings.TrimSuffix(p, "/...")
+			if root == "" {
+				root = "."
+			}
+
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !d.IsDir() {
+					return nil
+				}
+
+				name := d.Name()
+				if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+
+				add(path)
+
+				return nil
+			})
+
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(p, ".go"):
+			add(filepath.Dir(p))
+		default:
+			add(p)
+		}
+	}
+
+	return dirs, nil
+}
+
+// loadPackage parses and type-checks every Go file of the package in dir,
+// skipping test files and output, the file the generator writes its result to.
+// A directory without Go files yields a nil package and no error.
+func loadPackage(fileSet *token.FileSet, imp types.Importer, dir, output string) (*Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		if _, ok := err.(*build.NoGoError); ok {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	outAbs, err := filepath.Abs(output)
+	if err != nil {
+		return nil, err
+	}
+
+	names := append([]string{}, bp.GoFiles...)
+	sort.Strings(names)
+
+	pkg := &Package{Name: bp.Name, Dir: dir, Output: output}
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+
+		if abs, _ := filepath.Abs(path); abs == outAbs {
+			continue
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := parser.ParseFile(fileSet, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		pkg.Files = append(pkg.Files, file)
+	}
+
+	conf := types.Config{
+		Importer: imp,
+		// the package usually refers to the handlers we are about to
+		// generate (MyApi as an http.Handler), so errors are expected here;
+		// the checker still records everything it managed to resolve
+		Error: func(error) {},
+	}
+
+	pkg.Info = &types.Info{
+		Defs: map[*ast.Ident]types.Object{},
+	}
+	pkg.Types, _ = conf.Check(bp.ImportPath, fileSet, pkg.Files, pkg.Info)
+
+	return pkg, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go/importer"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandPatterns(t *testing.T) {
+	dirs, err := expandPatterns([]string{"../example/users.go", "../example", "../..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dirs[0] != filepath.Clean("../example") {
+		t.Errorf("a .go file must stand for its directory, got %v", dirs[0])
+	}
+
+	seen := map[string]int{}
+	for _, d := range dirs {
+		seen[d]++
+	}
+
+	for _, d := range []string{"..", "../example", "../handlers_gen"} {
+		if seen[filepath.Clean(d)] != 1 {
+			t.Errorf("%s expected once in %v", d, dirs)
+		}
+	}
+}
+
+func TestLoadPackageSkipsOutput(t *testing.T) {
+	fileSet := token.NewFileSet()
+
+	pkg, err := loadPackage(fileSet, importer.ForCompiler(fileSet, "source", nil), "../example", "../example/api_handlers.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range pkg.Files {
+		names = append(names, filepath.Base(fileSet.Position(f.Package).Filename))
+	}
+
+	if pkg.Name != "example" || !reflect.DeepEqual(names, []string{"params.go", "registry.go", "uploads.go", "users.go"}) {
+		t.Errorf("unexpected package %s with files %v", pkg.Name, names)
+	}
+}
@@ -0,0 +1,792 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Imports collects the packages referenced by the generated code and picks
+// a non-conflicting name for each of them.
+type Imports struct {
+	local *types.Package
+	names map[string]string // import path -> name used in the code
+	taken map[string]bool
+}
+
+func NewImports(local *types.Package, paths ...string) *Imports {
+	im := &Imports{
+		local: local,
+		names: map[string]string{},
+		taken: map[string]bool{},
+	}
+
+	for _, p := range paths {
+		im.Add(p, path.Base(p))
+	}
+
+	return im
+}
+
+// Add registers an import and returns the name it is referred to by.
+func (im *Imports) Add(importPath, name string) string {
+	if n, ok := im.names[importPath]; ok {
+		return n
+	}
+
+	n := name
+	for i := 2; im.taken[n]; i++ {
+		n = fmt.Sprintf("%s%d", name, i)
+	}
+
+	im.names[importPath] = n
+	im.taken[n] = true
+
+	return n
+}
+
+// Qualifier is a types.Qualifier that imports every package it sees
+// except the one the code is generated for.
+func (im *Imports) Qualifier(p *types.Package) string {
+	if p == im.local {
+		return ""
+	}
+
+	return im.Add(p.Path(), p.Name())
+}
+
+func (im *Imports) Write(w io.Writer) {
+	var paths []string
+	for p := range im.names {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if n := im.names[p]; n != path.Base(p) {
+			fmt.Fprintf(w, "import %s %q\n", n, p)
+		} else {
+			fmt.Fprintf(w, "import %q\n", p)
+		}
+	}
+}
+
+// derefType strips one pointer from t and tells whether there was one.
+func derefType(t types.Type) (types.Type, bool) {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem(), true
+	}
+
+	return t, false
+}
+
+// checkFieldRef tells whether the cross-field option of f can refer to other,
+// the value of required_if is rewritten as parsed for the generated code.
+func checkFieldRef(option string, f, other *FieldDef) error {
+	if other.File {
+		return fmt.Errorf("%s doesn't apply to files, %s is one", option, other.Name)
+	}
+
+	switch option {
+	case "required_if":
+		var basic *types.Basic
+		if obj := types.Universe.Lookup(other.TypeName); obj != nil {
+			basic, _ = obj.Type().(*types.Basic)
+		}
+
+		if basic == nil || other.Slice || other.TextUnmarshaler {
+			return fmt.Errorf("required_if needs a field of a basic type, %s is not", other.Name)
+		}
+
+		// compared as parsed: Admin:t is Admin:true, Level:010 is Level:10
+		lit, err := parseLiteral(f.ValidatorMeta.RequiredIfValue, basic.Kind())
+		if err != nil {
+			return fmt.Errorf("required_if value %v", err)
+		}
+
+		f.ValidatorMeta.RequiredIfValue = lit
+	case "eqfield", "gtfield":
+		if f.Slice || other.Slice || f.TextUnmarshaler || other.TextUnmarshaler || f.TypeName != other.TypeName {
+			return fmt.Errorf("%s needs %s to be of the same basic or time type", option, other.Name)
+		}
+
+		if option == "gtfield" && f.TypeName == "bool" {
+			return fmt.Errorf("gtfield doesn't apply to bool")
+		}
+	}
+
+	return nil
+}
+
+// newStructDef describes the parameters struct st declared under name.
+// Only the fields tagged with apivalidator or json are bound from the request,
+// fields of embedded structs are promoted into the parent's parameter set and
+// fields of nested ones are bound from dotted keys: filter.status.
+// The errors of all the fields are joined, each of them has its position.
+func newStructDef(name string, st *types.Struct, local *types.Package, imports *Imports) (*StructDef, error) {
+	struc := &StructDef{Name: name}
+
+	errs := struc.addFields(st, "", "", "", local, imports, map[*types.Struct]bool{st: true})
+
+	names := map[string]*FieldDef{}
+	params := map[string]*FieldDef{}
+	jsonKeys := map[string]*FieldDef{}
+
+	for _, f := range struc.Fields {
+		// fields of nested structs don't clash with the parent's ones
+		if other, ok := names[f.Prefix+f.Name]; ok {
+			errs = append(errs, errorAt(f.Pos, "%s: field %s is promoted from both %s and %s", name, f.Name, other.Path, f.Path))
+
+			continue
+		}
+
+		if other, ok := params[f.ParamName()]; ok {
+			errs = append(errs, errorAt(f.Pos, "%s: param %s is bound to both %s and %s", name, f.ParamName(), other.Path, f.Path))
+
+			continue
+		}
+
+		if other, ok := jsonKeys[f.JSONName]; ok && f.JSONName != "" {
+			errs = append(errs, errorAt(f.Pos, "%s: JSON key %s is bound to both %s and %s", name, f.JSONName, other.Path, f.Path))
+
+			continue
+		}
+
+		names[f.Prefix+f.Name] = f
+		params[f.ParamName()] = f
+		jsonKeys[f.JSONName] = f
+	}
+
+	// Filter.Age and a field named Filter_Age would share the flags of the
+	// generated handler, the later one is numbered
+	flags := map[string]bool{}
+	for _, f := range struc.Fields {
+		flag := f.flagName()
+		for n := 2; flags[flag]; n++ {
+			flag = fmt.Sprintf("%s_%d", f.flagName(), n)
+		}
+
+		flags[flag] = true
+		f.flag = flag
+	}
+
+	// the cross-field options refer to the fields of the same struct,
+	// nested ones to the fields of the same nested struct
+	for _, f := range struc.Fields {
+		for _, ref := range f.ValidatorMeta.fieldRefs() {
+			option, refName := ref[0], ref[1]
+			other, ok := names[f.Prefix+refName]
+
+			switch {
+			case !ok:
+				errs = append(errs, errorAt(f.Pos, "%s.%s: %s refers to unknown field %s", name, f.Path, option, refName))
+			case other == f:
+				errs = append(errs, errorAt(f.Pos, "%s.%s: %s refers to the field itself", name, f.Path, option))
+			default:
+				if err := checkFieldRef(option, f, other); err != nil {
+					errs = append(errs, errorAt(f.Pos, "%s.%s: %v", name, f.Path, err))
+
+					continue
+				}
+
+				if f.Refs == nil {
+					f.Refs = map[string]*FieldDef{}
+				}
+
+				f.Refs[option] = other
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return struc, nil
+}
+
+// addFields adds the fields of st, prefix is the selector of st from the
+// parameters struct, paramPrefix and jsonPrefix the param names and JSON keys
+// of the nested structs on the way to it. A field with an error is skipped
+// and the rest are added.
+func (struc *StructDef) addFields(st *types.Struct, prefix, paramPrefix, jsonPrefix string, local *types.Package, imports *Imports, visiting map[*types.Struct]bool) []error {
+	var errs []error
+
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		path := prefix + v.Name()
+
+		inner, isPointer := derefType(v.Type())
+		ist, isStruct := inner.Underlying().(*types.Struct)
+
+		// *multipart.FileHeader is an uploaded file, not a nested struct
+		isStruct = isStruct && !isFileHeader(inner)
+
+		if isStruct && visiting[ist] {
+			errs = append(errs, errorAt(v.Pos(), "%s.%s: struct contains itself", struc.Name, path))
+
+			continue
+		}
+
+		switch {
+		case isStruct && v.Embedded():
+			if isPointer {
+				struc.Allocs = append(struc.Allocs, fmt.Sprintf("params.%s = &%s{}", path, types.TypeString(inner, imports.Qualifier)))
+			}
+
+			// json:"-" leaves the promoted fields out of JSON as well
+			promotedJSONPrefix := jsonPrefix
+			if tag.Get("json") == "-" {
+				promotedJSONPrefix = "-"
+			}
+
+			visiting[ist] = true
+			errs = append(errs, struc.addFields(ist, path+".", paramPrefix, promotedJSONPrefix, local, imports, visiting)...)
+			delete(visiting, ist)
+		case isStruct && timeTypeName(inner) == "" && !isTextUnmarshaler(inner):
+			visiting[ist] = true
+			errs = append(errs, struc.addNested(v, ist, inner, isPointer, path, paramPrefix, jsonPrefix, tag, local, imports, visiting)...)
+			delete(visiting, ist)
+		case tag.Get("apivalidator") == "" && tag.Get("json") == "":
+		default:
+			field, err := struc.newField(v, tag, path, paramPrefix, jsonPrefix, local, imports)
+			if err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			struc.Fields = append(struc.Fields, field)
+		}
+	}
+
+	return errs
+}
+
+// newField describes the bound field v, its errors are positioned at v.
+func (struc *StructDef) newField(v *types.Var, tag reflect.StructTag, path, paramPrefix, jsonPrefix string, local *types.Package, imports *Imports) (*FieldDef, error) {
+	fail := func(format string, args ...interface{}) error {
+		return errorAt(v.Pos(), "%s.%s: %s", struc.Name, path, fmt.Sprintf(format, args...))
+	}
+
+	if !v.Exported() && v.Pkg() != local {
+		return nil, fail("unexported field of another package can't be set")
+	}
+
+	if isSlice, ok := fileType(v.Type()); ok {
+		return struc.newFileField(v, tag, path, paramPrefix, jsonPrefix, isSlice, imports, fail)
+	}
+
+	fieldType, isPointer := derefType(v.Type())
+	isSlice := false
+
+	// a []byte-based type like net.IP parses itself as a whole
+	if slice, ok := fieldType.Underlying().(*types.Slice); ok && !isPointer && !isTextUnmarshaler(fieldType) {
+		fieldType, isSlice = slice.Elem(), true
+	}
+
+	// the type checker errors are dropped by loadPackage, an undefined
+	// type would be generated as "invalid type"
+	if !isValid(fieldType) {
+		return nil, fail("the type is undefined or doesn't compile")
+	}
+
+	typeName := timeTypeName(fieldType)
+	unmarshaler := typeName == "" && isTextUnmarshaler(fieldType)
+	var kind types.BasicKind
+
+	if unmarshaler {
+		typeName = "string"
+	} else if typeName == "" {
+		basic, ok := fieldType.Underlying().(*types.Basic)
+		if !ok {
+			return nil, fail("unsupported type %s", v.Type())
+		}
+
+		if _, ok := literalBits[basic.Kind()]; !ok {
+			return nil, fail("unsupported type %s", v.Type())
+		}
+
+		kind = basic.Kind()
+		typeName = types.Typ[kind].Name() // byte is uint8, rune is int32
+	}
+
+	field := &FieldDef{
+		Name:            v.Name(),
+		Path:            path,
+		Pos:             v.Pos(),
+		Prefix:          paramPrefix,
+		TypeName:        typeName,
+		GoTypeName:      types.TypeString(fieldType, imports.Qualifier),
+		Pointer:         isPointer,
+		Slice:           isSlice,
+		TextUnmarshaler: unmarshaler,
+		Tag:             tag,
+		ValidatorMeta:   &FieldValidator{},
+	}
+
+	if err := field.ValidatorMeta.Parse(string(tag)); err != nil {
+		return nil, fail("tag %s: %v", tag, err)
+	}
+
+	field.JSONName = jsonName(jsonPrefix, tag, field.ValidatorMeta.ParamName, v.Name())
+
+	// type Role string with its consts is an enum by itself
+	if meta := field.ValidatorMeta; len(meta.Enum) == 0 && kind != types.Invalid {
+		meta.Enum = constValues(fieldType)
+		field.ConstEnum = len(meta.Enum) > 0
+	}
+
+	if !isSlice && (field.ValidatorMeta.IsMinItems || field.ValidatorMeta.IsMaxItems || field.ValidatorMeta.Split != "") {
+		return nil, fail("minitems, maxitems and split apply to slices only")
+	}
+
+	if field.ValidatorMeta.Layout != "" && typeName != "time.Time" {
+		return nil, fail("layout applies to time.Time only")
+	}
+
+	if meta := field.ValidatorMeta; (meta.Pattern != "" || meta.Format != "") && (typeName != "string" || unmarshaler) {
+		return nil, fail("pattern and format apply to strings only")
+	}
+
+	if meta := field.ValidatorMeta; meta.MaxSizeLit != "" || len(meta.ContentTypes) > 0 {
+		return nil, fail("maxsize and contenttype apply to *multipart.FileHeader fields only")
+	}
+
+	// trim applies to any value, the others would spoil numbers and dates
+	if meta := field.ValidatorMeta; (meta.Collapse || meta.Lower || meta.Upper) && typeName != "string" && !unmarshaler {
+		return nil, fail("collapse, lower and upper apply to strings and TextUnmarshaler types only")
+	}
+
+	var err error
+	if unmarshaler {
+		if meta := field.ValidatorMeta; meta.IsMin || meta.IsMax || len(meta.Enum) > 0 {
+			err = fmt.Errorf("min, max and enum don't apply to %s, it is parsed by UnmarshalText", field.GoTypeName)
+		}
+	} else if kind == types.Invalid {
+		err = checkTimeLiterals(field.ValidatorMeta, typeName)
+	} else {
+		err = checkLiterals(field.ValidatorMeta, kind)
+	}
+
+	if err != nil {
+		return nil, fail("%v", err)
+	}
+
+	return field, nil
+}
+
+// fileOptions are the options of apivalidator that apply to files.
+var fileOptions = []string{"paramname", "required", "maxsize", "contenttype", "minitems", "maxitems"}
+
+// newFileField describes the uploaded file field v, a *multipart.FileHeader
+// or a slice of them bound from the files of a multipart body.
+func (struc *StructDef) newFileField(v *types.Var, tag reflect.StructTag, path, paramPrefix, jsonPrefix string, isSlice bool, imports *Imports, fail func(string, ...interface{}) error) (*FieldDef, error) {
+	fileType := v.Type()
+	if isSlice {
+		fileType = fileType.Underlying().(*types.Slice).Elem()
+	}
+
+	field := &FieldDef{
+		Name:          v.Name(),
+		Path:          path,
+		Pos:           v.Pos(),
+		Prefix:        paramPrefix,
+		TypeName:      "file",
+		GoTypeName:    types.TypeString(fileType, imports.Qualifier),
+		Slice:         isSlice,
+		File:          true,
+		Tag:           tag,
+		ValidatorMeta: &FieldValidator{},
+	}
+
+	meta := field.ValidatorMeta
+	if err := meta.Parse(string(tag)); err != nil {
+		return nil, fail("tag %s: %v", tag, err)
+	}
+
+	field.JSONName = jsonName(jsonPrefix, tag, meta.ParamName, v.Name())
+
+	if meta.otherOption(fileOptions...) != "" {
+		return nil, fail("only %s apply to files", joinOptions(fileOptions))
+	}
+
+	if !isSlice && (meta.IsMinItems || meta.IsMaxItems) {
+		return nil, fail("minitems, maxitems and split apply to slices only")
+	}
+
+	return field, nil
+}
+
+// addNested binds the fields of the nested struct field v of type nested,
+// keyed by its param name and a dot. Only paramname applies to the field
+// itself, the rules are set on the fields of the nested struct.
+func (struc *StructDef) addNested(v *types.Var, st *types.Struct, nested types.Type, isPointer bool, path, paramPrefix, jsonPrefix string, tag reflect.StructTag, local *types.Package, imports *Imports, visiting map[*types.Struct]bool) []error {
+	meta := &FieldValidator{}
+	if err := meta.Parse(string(tag)); err != nil {
+		return []error{errorAt(v.Pos(), "%s.%s: tag %s: %v", struc.Name, path, tag, err)}
+	}
+
+	if meta.otherOption("paramname") != "" {
+		return []error{errorAt(v.Pos(), "%s.%s: only paramname applies to a nested struct", struc.Name, path)}
+	}
+
+	fields, allocs := len(struc.Fields), len(struc.Allocs)
+
+	if isPointer {
+		struc.Allocs = append(struc.Allocs, fmt.Sprintf("params.%s = &%s{}", path, types.TypeString(nested, imports.Qualifier)))
+	}
+
+	nestedJSONPrefix := "-"
+	if key := jsonName(jsonPrefix, tag, meta.ParamName, v.Name()); key != "" {
+		nestedJSONPrefix = key + "."
+	}
+
+	errs := struc.addFields(st, path+".", paramPrefix+ParamName(meta.ParamName, v.Name())+".", nestedJSONPrefix, local, imports, visiting)
+
+	// a struct without bound fields is not a parameter
+	if len(struc.Fields) == fields {
+		struc.Allocs = struc.Allocs[:allocs]
+
+		return errs
+	}
+
+	if !v.Exported() && v.Pkg() != local {
+		errs = append(errs, errorAt(v.Pos(), "%s.%s: unexported field of another package can't be set", struc.Name, path))
+	}
+
+	return errs
+}
+
+// jsonName is the key of the field in a JSON body under jsonPrefix: the name
+// of its json tag, or its param name when the tag has none. It is empty for
+// a field left out of JSON with json:"-" and for the fields of a nested
+// struct left out, whose jsonPrefix is "-".
+func jsonName(jsonPrefix string, tag reflect.StructTag, paramName, name string) string {
+	if jsonPrefix == "-" || tag.Get("json") == "-" {
+		return ""
+	}
+
+	if key, _, _ := strings.Cut(tag.Get("json"), ","); key != "" {
+		return jsonPrefix + key
+	}
+
+	return jsonPrefix + ParamName(paramName, name)
+}
+
+// constValues lists the values of the constants declared with the named type
+// t in its package, in the order of declaration.
+func constValues(t types.Type) []string {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil
+	}
+
+	var consts []*types.Const
+
+	scope := named.Obj().Pkg().Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && types.Identical(c.Type(), t) {
+			consts = append(consts, c)
+		}
+	}
+
+	sort.Slice(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+
+	var values []string
+	seen := map[string]bool{}
+
+	for _, c := range consts {
+		var value string
+
+		switch c.Val().Kind() {
+		case constant.String:
+			value = constant.StringVal(c.Val())
+		case constant.Float:
+			f, _ := constant.Float64Val(c.Val())
+			value = strconv.FormatFloat(f, 'g', -1, 64)
+		default:
+			value = c.Val().ExactString()
+		}
+
+		// aliases like RoleDefault = RoleUser
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// literalBits holds the supported kinds of parameters with the bit size
+// their literals are parsed with.
+var literalBits = map[types.BasicKind]int{
+	types.Bool:    0,
+	types.String:  0,
+	types.Int:     64,
+	types.Int8:    8,
+	types.Int16:   16,
+	types.Int32:   32,
+	types.Int64:   64,
+	types.Uint:    64,
+	types.Uint8:   8,
+	types.Uint16:  16,
+	types.Uint32:  32,
+	types.Uint64:  64,
+	types.Float32: 32,
+	types.Float64: 64,
+}
+
+// checkLiterals tells whether the min, max, enum and default values of the
+// tag fit a field of the given kind, so that the generated code compiles.
+// min, max and enum are rewritten in the form strconv formats them, which
+// the generated code compares with: 010 is 10, not an octal 8.
+func checkLiterals(meta *FieldValidator, kind types.BasicKind) error {
+	if kind == types.Bool && (meta.IsMin || meta.IsMax) {
+		return fmt.Errorf("min and max don't apply to bool")
+	}
+
+	for _, lit := range []struct {
+		set   bool
+		value *string
+	}{{meta.IsMin, &meta.MinLit}, {meta.IsMax, &meta.MaxLit}} {
+		if !lit.set {
+			continue
+		}
+
+		if kind == types.String {
+			// a length
+			n, err := strconv.Atoi(*lit.value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("length %q is not a non-negative int", *lit.value)
+			}
+
+			*lit.value = strconv.Itoa(n)
+
+			continue
+		}
+
+		value, err := parseLiteral(*lit.value, kind)
+		if err != nil {
+			return err
+		}
+
+		*lit.value = value
+	}
+
+	for i, e := range meta.Enum {
+		value, err := parseLiteral(e, kind)
+		if err != nil {
+			return fmt.Errorf("enum value %v", err)
+		}
+
+		meta.Enum[i] = value
+	}
+
+	for _, item := range defaultItems(meta) {
+		if _, err := parseLiteral(item, kind); err != nil {
+			return fmt.Errorf("default %v", err)
+		}
+	}
+
+	return nil
+}
+
+// defaultItems are the values the default stands for: the items of a split
+// slice each, without the empty ones formItems drops.
+func defaultItems(meta *FieldValidator) []string {
+	if !meta.HasDefault() {
+		return nil
+	}
+
+	if meta.Split == "" {
+		return []string{meta.Default}
+	}
+
+	var items []string
+	for _, item := range strings.Split(meta.Default, meta.Split) {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// parseLiteral parses the tag value s as a literal of the basic kind and
+// returns it as strconv formats the value: t is true, 010 is 10. The value
+// of a string is s itself.
+func parseLiteral(s string, kind types.BasicKind) (string, error) {
+	bits := literalBits[kind]
+
+	var (
+		lit string
+		err error
+	)
+
+	switch {
+	case kind == types.String:
+		lit = s
+	case kind == types.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		lit = strconv.FormatBool(b)
+	case kind >= types.Int && kind <= types.Int64:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, bits)
+		lit = strconv.FormatInt(n, 10)
+	case kind >= types.Uint && kind <= types.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, bits)
+		lit = strconv.FormatUint(n, 10)
+	default:
+		// the handlers reject NaN and the infinities, so do the tags
+		var f float64
+		if f, err = strconv.ParseFloat(s, bits); err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			err = strconv.ErrSyntax
+		}
+
+		lit = strconv.FormatFloat(f, 'g', -1, bits)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid %s", s, types.Typ[kind].Name())
+	}
+
+	return lit, nil
+}
+
+// timeTypeName tells whether t is time.Time or time.Duration, which are
+// bound by their own parsers rather than as a struct and an int64.
+func timeTypeName(t types.Type) string {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "time" {
+		return ""
+	}
+
+	switch named.Obj().Name() {
+	case "Time", "Duration":
+		return "time." + named.Obj().Name()
+	}
+
+	return ""
+}
+
+// textUnmarshaler is encoding.TextUnmarshaler, built by hand since the
+// package being generated for doesn't have to import encoding.
+var textUnmarshaler = types.NewInterfaceType([]*types.Func{
+	types.NewFunc(token.NoPos, nil, "UnmarshalText", types.NewSignatureType(nil, nil, nil,
+		types.NewTuple(types.NewVar(token.NoPos, nil, "text", types.NewSlice(types.Typ[types.Byte]))),
+		types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type())),
+		false)),
+}, nil).Complete()
+
+// isFileHeader tells whether t is multipart.FileHeader.
+func isFileHeader(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+
+	obj := named.Obj()
+
+	return obj.Pkg() != nil && obj.Pkg().Path() == "mime/multipart" && obj.Name() == "FileHeader"
+}
+
+// fileType tells whether t is *multipart.FileHeader or a slice of them.
+func fileType(t types.Type) (isSlice bool, ok bool) {
+	if slice, ok := t.Underlying().(*types.Slice); ok {
+		t, isSlice = slice.Elem(), true
+	}
+
+	if p, ok := t.(*types.Pointer); ok && isFileHeader(p.Elem()) {
+		return isSlice, true
+	}
+
+	return false, false
+}
+
+// isValid tells whether t type-checked: an undefined type, or a named type
+// declared with one, is left invalid by the type checker.
+func isValid(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+
+	return !ok || basic.Kind() != types.Invalid
+}
+
+// isTextUnmarshaler tells whether a field of type t can be set with
+// UnmarshalText, which usually has a pointer receiver.
+func isTextUnmarshaler(t types.Type) bool {
+	if !isValid(t) {
+		return false
+	}
+
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return false
+	}
+
+	if _, ok := t.(*types.Pointer); ok {
+		return false
+	}
+
+	return types.Implements(types.NewPointer(t), textUnmarshaler)
+}
+
+// checkTimeLiterals is checkLiterals for time.Time, whose min, max and
+// default are dates in the layout of the field, and time.Duration.
+func checkTimeLiterals(meta *FieldValidator, typeName string) error {
+	parse := func(s string) error {
+		var err error
+
+		if typeName == "time.Time" {
+			_, err = time.Parse(meta.TimeLayout(), s)
+		} else {
+			_, err = time.ParseDuration(s)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%q is not a valid %s", s, typeName)
+		}
+
+		return nil
+	}
+
+	if len(meta.Enum) > 0 {
+		return fmt.Errorf("enum doesn't apply to %s", typeName)
+	}
+
+	if meta.IsMin {
+		if err := parse(meta.MinLit); err != nil {
+			return err
+		}
+	}
+
+	if meta.IsMax {
+		if err := parse(meta.MaxLit); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range defaultItems(meta) {
+		if err := parse(item); err != nil {
+			return fmt.Errorf("default %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,1696 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkSource type-checks a single file package the way loadPackage does.
+func checkSource(t *testing.T, src string) *Package {
+	t.Helper()
+
+	fileSet := token.NewFileSet()
+
+	file, err := parser.ParseFile(fileSet, "api.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := &Package{
+		Name:  file.Name.Name,
+		Files: []*ast.File{file},
+		Info:  &types.Info{Defs: map[*ast.Ident]types.Object{}},
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fileSet, "source", nil), Error: func(error) {}}
+	pkg.Types, _ = conf.Check("api", fileSet, pkg.Files, pkg.Info)
+
+	return pkg
+}
+
+func TestInspectFuncSignatureTypes(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import (
+	"context"
+	"net/url"
+)
+
+type Login string
+
+type Params struct {
+	Login Login  `+"`apivalidator:\"required\"`"+`
+	Age   int    `+"`apivalidator:\"min=0\"`"+`
+	Skip  string
+}
+
+type Api struct{}
+
+type Result struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in *Params) (*Result, error) { return nil, nil }
+
+// apigen:api {"url": "/b"}
+func (a *Api) Values(ctx context.Context, in url.Values) (*Result, error) { return nil, nil }
+`)
+
+	imports := NewImports(pkg.Types)
+	var funcs []*FuncDef
+
+	for _, d := range pkg.Files[0].Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok {
+			fc := &FuncDef{}
+			err := inspectFuncSignature(pkg, fd, fc, imports)
+
+			if fd.Name.Name == "Values" {
+				if err == nil {
+					t.Error("url.Values is not a struct, error expected")
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			funcs = append(funcs, fc)
+		}
+	}
+
+	fc := funcs[0]
+
+	if fc.ReceiverName != "Api" || fc.ArgumentTypeName != "Params" || !fc.ArgumentPointer || fc.ResulTypeName != "Result" {
+		t.Errorf("unexpected signature %+v", fc)
+	}
+
+	fields := fc.ArgumentStruct.Fields
+	if len(fields) != 2 {
+		t.Fatalf("untagged fields must be skipped, got %v", fields)
+	}
+
+	if fields[0].TypeName != "string" || fields[0].GoTypeName != "Login" {
+		t.Errorf("named type expected to convert from string, got %+v", fields[0])
+	}
+
+	if fields[1].GoTypeName != "int" {
+		t.Errorf("unexpected %+v", fields[1])
+	}
+
+	if got := fields[0].GenParamAssign(); !strings.Contains(got, `params.Login = Login(sv)`) {
+		t.Errorf("unexpected %s", got)
+	}
+}
+
+func argumentStruct(t *testing.T, pkg *Package, method string) (*StructDef, error) {
+	t.Helper()
+
+	for _, d := range pkg.Files[0].Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Name.Name == method {
+			fc := &FuncDef{}
+			err := inspectFuncSignature(pkg, fd, fc, NewImports(pkg.Types))
+
+			return fc.ArgumentStruct, err
+		}
+	}
+
+	t.Fatalf("no method %s", method)
+
+	return nil, nil
+}
+
+func TestEmbeddedParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Pagination struct {
+	Limit  int `+"`apivalidator:\"default=10\"`"+`
+	Offset int `+"`apivalidator:\"default=0\"`"+`
+}
+
+type TenantScope struct {
+	Tenant string `+"`apivalidator:\"required\"`"+`
+}
+
+type OtherPagination struct {
+	Limit int `+"`apivalidator:\"paramname=other_limit\"`"+`
+}
+
+type ListParams struct {
+	Query string `+"`apivalidator:\"required\"`"+`
+	Pagination
+	*TenantScope
+}
+
+type ConflictParams struct {
+	Pagination
+	OtherPagination
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/list"}
+func (a *Api) List(ctx context.Context, in ListParams) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/conflict"}
+func (a *Api) Conflict(ctx context.Context, in ConflictParams) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "List")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, f := range sd.Fields {
+		paths = append(paths, f.Path+"="+f.ParamName())
+	}
+
+	expected := []string{"Query=query", "Pagination.Limit=limit", "Pagination.Offset=offset", "TenantScope.Tenant=tenant"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("got %v, expected %v", paths, expected)
+	}
+
+	if !reflect.DeepEqual(sd.Allocs, []string{"params.TenantScope = &TenantScope{}"}) {
+		t.Errorf("pointer embedded struct must be allocated, got %v", sd.Allocs)
+	}
+
+	if _, err := argumentStruct(t, pkg, "Conflict"); err == nil || !strings.Contains(err.Error(), "field Limit is promoted from both") {
+		t.Errorf("conflict expected, got %v", err)
+	}
+}
+
+func TestPointerParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Name *string `+"`apivalidator:\"required,min=2\"`"+`
+	Age  *int    `+"`apivalidator:\"enum=1|2\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, age := sd.Fields[0], sd.Fields[1]
+
+	if !name.Pointer || name.TypeName != "string" {
+		t.Errorf("unexpected %+v", name)
+	}
+
+	if got := name.GenParamAssign(); !strings.Contains(got, "params.Name = &val") {
+		t.Errorf("unexpected %s", got)
+	}
+
+	checks := name.GenChecks()
+	for _, expected := range []string{"params.Name == nil", `*params.Name == ""`, "len(*params.Name) < 2"} {
+		if !strings.Contains(checks, expected) {
+			t.Errorf("%q expected in\n%s", expected, checks)
+		}
+	}
+
+	if checks := age.GenChecks(); !strings.Contains(checks, "*params.Age != 1 && *params.Age != 2") {
+		t.Errorf("int enum expected in\n%s", checks)
+	}
+}
+
+func TestSliceParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Tag string
+
+type Params struct {
+	Tags []Tag `+"`apivalidator:\"required,maxitems=3,enum=a|b\"`"+`
+	IDs  []int `+"`apivalidator:\"paramname=id,split=,,min=1\"`"+`
+}
+
+type BadParams struct {
+	Login string `+"`apivalidator:\"minitems=1\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/b"}
+func (a *Api) Bad(ctx context.Context, in BadParams) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, ids := sd.Fields[0], sd.Fields[1]
+
+	if !tags.Slice || tags.TypeName != "string" || tags.GoTypeName != "Tag" {
+		t.Errorf("unexpected %+v", tags)
+	}
+
+	if got := tags.GenParamAssign(); !strings.Contains(got, "params.Tags = append(params.Tags, Tag(item))") {
+		t.Errorf("named items must be converted one by one, got %s", got)
+	}
+
+	checks := tags.GenChecks()
+	for _, expected := range []string{"len(params.Tags) == 0", "len(params.Tags) > 3", `item != "a" && item != "b"`} {
+		if !strings.Contains(checks, expected) {
+			t.Errorf("%q expected in\n%s", expected, checks)
+		}
+	}
+
+	if got := ids.GenParamAssign(); !strings.Contains(got, `range formItems(r.Form, "id", "", ",", "")`) {
+		t.Errorf("unexpected %s", got)
+	}
+
+	if _, err := argumentStruct(t, pkg, "Bad"); err == nil {
+		t.Error("minitems on a string expected to fail")
+	}
+}
+
+func TestScalarParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Flag  bool    `+"`apivalidator:\"required\"`"+`
+	Small int8    `+"`apivalidator:\"min=-5,max=5\"`"+`
+	Big   uint64  `+"`apivalidator:\"max=18446744073709551615\"`"+`
+	Ratio float64 `+"`apivalidator:\"min=0.25\"`"+`
+	Char  byte    `+"`apivalidator:\"enum=1|2\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range sd.Fields {
+		names = append(names, f.TypeName)
+	}
+
+	for i, expected := range []string{
+		"strconv.ParseBool(sv)",
+		"strconv.ParseInt(sv, 10, 8)",
+		"strconv.ParseUint(sv, 10, 64)",
+		"parseFloat(sv, 64)",
+		"strconv.ParseUint(sv, 10, 8)",
+	} {
+		if assign := sd.Fields[i].GenParamAssign(); !strings.Contains(assign, expected) {
+			t.Errorf("%s expected in\n%s", expected, assign)
+		}
+	}
+
+	if !reflect.DeepEqual(names, []string{"bool", "int8", "uint64", "float64", "uint8"}) {
+		t.Errorf("unexpected types %v", names)
+	}
+
+	for i, expected := range []string{
+		"params.Flag == false",
+		"params.Small < -5",
+		"params.Big > 18446744073709551615",
+		"params.Ratio < 0.25",
+		"params.Char != 1 && params.Char != 2",
+	} {
+		if checks := sd.Fields[i].GenChecks(); !strings.Contains(checks, expected) {
+			t.Errorf("%q expected in\n%s", expected, checks)
+		}
+	}
+}
+
+func TestCanonicalLiterals(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+type Params struct {
+	Admin bool    `+"`apivalidator:\"enum=t|F\"`"+`
+	Code  int     `+"`apivalidator:\"enum=010|020\"`"+`
+	Age   uint8   `+"`apivalidator:\"min=010,max=099\"`"+`
+	Rate  float32 `+"`apivalidator:\"min=1e1,max=0050.50\"`"+`
+	Name  string  `+"`apivalidator:\"min=03\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(in Params) error { return nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the literals are compared and reported as the values they were parsed as
+	for i, expected := range []string{
+		`params.Admin != true && params.Admin != false`,
+		`admin must be one of [true, false]`,
+		`params.Code != 10 && params.Code != 20`,
+		`code must be one of [10, 20]`,
+		`params.Age < 10`,
+		`age must be <= 99`,
+		`params.Rate > 50.5`,
+		`rate must be >= 10`,
+		`len(params.Name) < 3`,
+		`name len must be >= 3`,
+	} {
+		if checks := sd.Fields[i/2].GenChecks(); !strings.Contains(checks, expected) {
+			t.Errorf("%s expected in\n%s", expected, checks)
+		}
+	}
+}
+
+func TestCheckLiterals(t *testing.T) {
+	for _, c := range []struct {
+		kind types.BasicKind
+		tag  string
+		ok   bool
+	}{
+		{types.Int8, `apivalidator:"max=127"`, true},
+		{types.Int8, `apivalidator:"max=128"`, false},
+		{types.Uint, `apivalidator:"min=-1"`, false},
+		{types.Int, `apivalidator:"min=0.5"`, false},
+		{types.Float32, `apivalidator:"min=0.5,default=1.5"`, true},
+		{types.Bool, `apivalidator:"min=1"`, false},
+		{types.Bool, `apivalidator:"default=true"`, true},
+		{types.String, `apivalidator:"min=-1"`, false},
+		{types.Int, `apivalidator:"enum=1|two"`, false},
+		{types.Uint16, `apivalidator:"default=70000"`, false},
+		{types.Int, `apivalidator:"split=;,default=1;2;"`, true},
+		{types.Int, `apivalidator:"split=;,default=1;x"`, false},
+		{types.Float64, `apivalidator:"max=NaN"`, false},
+		{types.Float32, `apivalidator:"default=+Inf"`, false},
+	} {
+		meta := &FieldValidator{}
+		if err := meta.Parse(c.tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := checkLiterals(meta, c.kind); (err == nil) != c.ok {
+			t.Errorf("%s %s: unexpected %v", types.Typ[c.kind], c.tag, err)
+		}
+	}
+}
+
+func TestTimeParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import (
+	"context"
+	"time"
+)
+
+type Params struct {
+	From    time.Time       `+"`apivalidator:\"required,layout=DateOnly,min=2020-01-01\"`"+`
+	To      *time.Time      `+"`apivalidator:\"layout=02.01.2006,max=31.12.2030\"`"+`
+	Timeout time.Duration   `+"`apivalidator:\"default=5s,max=1m\"`"+`
+	Delays  []time.Duration `+"`apivalidator:\"split=,,min=1ms\"`"+`
+	At      time.Time       `+"`apivalidator:\"paramname=at,min=2020-01-01T00:00:00Z\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range sd.Fields {
+		names = append(names, f.TypeName)
+	}
+
+	if !reflect.DeepEqual(names, []string{"time.Time", "time.Time", "time.Duration", "time.Duration", "time.Time"}) {
+		t.Errorf("unexpected types %v", names)
+	}
+
+	for i, expected := range []string{
+		"params.From.IsZero()",
+		"(*params.To).After(time.Unix(1924905600, 0))",
+		"params.Timeout > 60000000000",
+		"item < 1000000",
+		"params.At.Before(time.Unix(1577836800, 0))",
+	} {
+		if checks := sd.Fields[i].GenChecks(); !strings.Contains(checks, expected) {
+			t.Errorf("%q expected in\n%s", expected, checks)
+		}
+	}
+
+	// RFC 3339 without a layout
+	for i, expected := range map[int]string{0: "time.DateOnly", 1: `"02.01.2006"`, 4: "time.RFC3339"} {
+		if input := sd.Fields[i].GenParamAssign(); !strings.Contains(input, "time.Parse("+expected+", sv)") {
+			t.Errorf("layout %s expected in %s", expected, input)
+		}
+	}
+}
+
+func TestCheckTimeLiterals(t *testing.T) {
+	for _, c := range []struct {
+		typeName string
+		tag      string
+		ok       bool
+	}{
+		{"time.Time", `apivalidator:"min=2020-01-01T00:00:00Z"`, true},
+		{"time.Time", `apivalidator:"min=2020-01-01"`, false},
+		{"time.Time", `apivalidator:"layout=DateOnly,min=2020-01-01,default=2021-06-01"`, true},
+		{"time.Time", `apivalidator:"layout=DateOnly,default=today"`, false},
+		{"time.Time", `apivalidator:"enum=2020-01-01T00:00:00Z"`, false},
+		{"time.Duration", `apivalidator:"min=1s,max=1h30m"`, true},
+		{"time.Duration", `apivalidator:"max=10"`, false},
+		{"time.Duration", `apivalidator:"split=;,default=1s;2m"`, true},
+		{"time.Duration", `apivalidator:"split=;,default=1s;2"`, false},
+	} {
+		meta := &FieldValidator{}
+		if err := meta.Parse(c.tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := checkTimeLiterals(meta, c.typeName); (err == nil) != c.ok {
+			t.Errorf("%s %s: unexpected %v", c.typeName, c.tag, err)
+		}
+	}
+}
+
+func TestTextUnmarshalerParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type ID struct{ n int }
+
+func (id *ID) UnmarshalText(text []byte) error { return nil }
+
+type Params struct {
+	ID     ID        `+"`apivalidator:\"required\"`"+`
+	Owner  *ID       `+"`apivalidator:\"paramname=owner\"`"+`
+	Refs   []ID      `+"`apivalidator:\"paramname=ref\"`"+`
+	Origin net.IP    `+"`apivalidator:\"paramname=origin\"`"+`
+	At     time.Time `+"`apivalidator:\"paramname=at\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []bool{true, true, true, true, false} {
+		if f := sd.Fields[i]; f.TextUnmarshaler != expected || f.Slice != (f.Name == "Refs") {
+			t.Errorf("%s: unexpected TextUnmarshaler %v, Slice %v", f.Name, f.TextUnmarshaler, f.Slice)
+		}
+	}
+
+	if assign := sd.Fields[1].GenParamAssign(); !strings.Contains(assign, "val.UnmarshalText([]byte(sv))") || !strings.Contains(assign, "params.Owner = &val") {
+		t.Errorf("unexpected assignment\n%s", assign)
+	}
+
+	if checks := sd.Fields[0].GenChecks(); !strings.Contains(checks, `if !sentID {`) {
+		t.Errorf("unexpected checks\n%s", checks)
+	}
+
+	_, err = argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type ID struct{ n int }
+
+func (id *ID) UnmarshalText(text []byte) error { return nil }
+
+type Params struct {
+	ID ID `+"`apivalidator:\"min=1\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+	if err == nil || !strings.Contains(err.Error(), "UnmarshalText") {
+		t.Errorf("min on a TextUnmarshaler expected to fail, got %v", err)
+	}
+}
+
+func TestNestedParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Range struct {
+	From int `+"`apivalidator:\"min=0\"`"+`
+	To   int
+}
+
+type Filter struct {
+	Status string `+"`apivalidator:\"enum=a|b\"`"+`
+	Age    *Range `+"`apivalidator:\"paramname=age\"`"+`
+}
+
+type Params struct {
+	Status string `+"`apivalidator:\"required\"`"+`
+	Filter Filter `+"`apivalidator:\"paramname=f\"`"+`
+	Extra  *Range
+	Unused struct{ n int }
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var params []string
+	for _, f := range sd.Fields {
+		params = append(params, f.fieldPath()+" "+f.ParamName())
+	}
+
+	if !reflect.DeepEqual(params, []string{"Status status", "Filter.Status f.status", "Filter.Age.From f.age.from", "Extra.From extra.from"}) {
+		t.Errorf("unexpected params %v", params)
+	}
+
+	if !reflect.DeepEqual(sd.Allocs, []string{"params.Filter.Age = &Range{}", "params.Extra = &Range{}"}) {
+		t.Errorf("unexpected allocs %v", sd.Allocs)
+	}
+
+	if checks := sd.Fields[2].GenChecks(); !strings.Contains(checks, `"f.age.from must be >= 0"`) {
+		t.Errorf("full path expected in\n%s", checks)
+	}
+
+	if checks := sd.Fields[1].GenChecks(); !strings.Contains(checks, `if sentFilter_Status {`) {
+		t.Errorf("optional string expected to be checked when sent\n%s", checks)
+	}
+
+	_, err = argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Filter struct {
+	Status string `+"`apivalidator:\"enum=a|b\"`"+`
+}
+
+type Params struct {
+	Filter Filter `+"`apivalidator:\"required\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+	if err == nil || !strings.Contains(err.Error(), "only paramname") {
+		t.Errorf("required on a nested struct expected to fail, got %v", err)
+	}
+}
+
+func TestFormValues(t *testing.T) {
+	values := url.Values{
+		"login":            {"ivan"},
+		"empty":            {""},
+		"filter[age][min]": {"1"},
+		"tag":              {"a,b", "", "c"},
+		"filter[tag]":      {"d"},
+	}
+
+	for _, c := range []struct {
+		key, bracketKey, def string
+		keepEmpty            bool
+		value                string
+		ok                   bool
+	}{
+		{"login", "", "", false, "ivan", true},
+		{"missing", "", "", false, "", false},
+		{"missing", "", "user", false, "user", true},
+		{"empty", "", "user", false, "user", true},
+		{"empty", "", "", true, "", true},
+		{"filter.age.min", "filter[age][min]", "", false, "1", true},
+	} {
+		if value, ok := formValue(values, c.key, c.bracketKey, c.def, c.keepEmpty); value != c.value || ok != c.ok {
+			t.Errorf("%s: unexpected %q, %v", c.key, value, ok)
+		}
+	}
+
+	if items := formItems(values, "tag", "", ",", ""); !reflect.DeepEqual(items, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected items %v", items)
+	}
+
+	if items := formItems(values, "filter.tag", "filter[tag]", "", ""); !reflect.DeepEqual(items, []string{"d"}) {
+		t.Errorf("unexpected items %v", items)
+	}
+
+	if items := formItems(values, "missing", "", ",", "x,y"); !reflect.DeepEqual(items, []string{"x", "y"}) {
+		t.Errorf("unexpected default items %v", items)
+	}
+
+	normalized := url.Values{
+		"login": {" RVasily "},
+		"name":  {"  Vasily \t Romanov "},
+		"blank": {"  "},
+		"tag":   {" a , B,  ", "c"},
+	}
+
+	for _, c := range []struct {
+		key       string
+		normalize []string
+		value     string
+		ok        bool
+	}{
+		{"login", nil, " RVasily ", true},
+		{"login", []string{"trim", "lower"}, "rvasily", true},
+		{"login", []string{"upper"}, " RVASILY ", true},
+		{"name", []string{"collapse"}, " Vasily Romanov ", true},
+		{"name", []string{"trim", "collapse"}, "Vasily Romanov", true},
+		// nothing left is a missing value
+		{"blank", []string{"trim"}, "", false},
+	} {
+		if value, ok := formValue(normalized, c.key, "", "", false, c.normalize...); value != c.value || ok != c.ok {
+			t.Errorf("%s %v: unexpected %q, %v", c.key, c.normalize, value, ok)
+		}
+	}
+
+	if items := formItems(normalized, "tag", "", ",", "", "trim", "lower"); !reflect.DeepEqual(items, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected normalized items %v", items)
+	}
+
+	def := &FieldDef{Name: "Min", Prefix: "filter.age.", ValidatorMeta: &FieldValidator{}}
+	if key := def.bracketKey(); key != "filter[age][min]" {
+		t.Errorf("unexpected bracket key %s", key)
+	}
+}
+
+func TestValidFormat(t *testing.T) {
+	for _, c := range []struct {
+		format, value string
+		valid         bool
+	}{
+		{"email", "ivan@mail.ru", true},
+		{"email", "ivan", false},
+		{"email", "Ivan <ivan@mail.ru>", false},
+		{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", true},
+		{"uuid", "6BA7B810-9DAD-11D1-80B4-00C04FD430C8", true},
+		{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430cg", false},
+		{"uuid", "6ba7b8109dad-11d1-80b4-00c04fd430c8-", false},
+		{"url", "https://example.com/a?b=c", true},
+		{"url", "example.com", false},
+		{"url", "/relative", false},
+		{"ip", "127.0.0.1", true},
+		{"ip", "::1", true},
+		{"ip", "256.0.0.1", false},
+		{"hostname", "api.example.com", true},
+		{"hostname", "localhost", true},
+		{"hostname", "-bad.example.com", false},
+		{"hostname", "bad..example.com", false},
+		{"hostname", "under_score.com", false},
+		{"phone", "100500", false},
+	} {
+		if validFormat(c.format, c.value) != c.valid {
+			t.Errorf("%s %q: expected valid %v", c.format, c.value, c.valid)
+		}
+	}
+
+	if _, err := argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Email string `+"`apivalidator:\"format=mail\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do"); err == nil || !strings.Contains(err.Error(), `unknown format "mail", expected email, uuid, url, ip or hostname`) {
+		t.Errorf("unknown format expected to fail, got %v", err)
+	}
+}
+
+func TestConstEnumParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import (
+	"context"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleAdmin   Role = "admin"
+	RoleDefault      = RoleUser
+	roleName         = "root"
+)
+
+type Level int
+
+const (
+	LevelLow Level = iota + 1
+	LevelHigh
+)
+
+type Params struct {
+	Role    Role          `+"`apivalidator:\"required\"`"+`
+	Levels  []Level       `+"`apivalidator:\"paramname=level\"`"+`
+	Admin   Role          `+"`apivalidator:\"enum=admin\"`"+`
+	Timeout time.Duration `+"`apivalidator:\"paramname=timeout\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range [][]string{{"user", "admin"}, {"1", "2"}, {"admin"}, nil} {
+		if f := sd.Fields[i]; !reflect.DeepEqual(f.ValidatorMeta.Enum, expected) {
+			t.Errorf("%s: unexpected enum %v", f.Name, f.ValidatorMeta.Enum)
+		}
+	}
+
+	if checks := sd.Fields[0].GenChecks(); !strings.Contains(checks, `"role must be one of [user, admin]"`) {
+		t.Errorf("enum message expected in\n%s", checks)
+	}
+}
+
+func TestRuleMessages(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Login  string `+"`apivalidator:\"required,min=10\"`"+`
+	Status string `+"`apivalidator:\"enum=user|moderator|admin,default=user\"`"+`
+	Age    int    `+"`apivalidator:\"min=0,max=128\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, messages := range [][]string{
+		{`"login must me not empty"`, `"login len must be >= 10"`},
+		{`"status must be one of [user, moderator, admin]"`},
+		{`"age must be >= 0"`, `"age must be <= 128"`},
+	} {
+		checks := sd.Fields[i].GenChecks()
+
+		for _, message := range messages {
+			if !strings.Contains(checks, message) {
+				t.Errorf("%s expected in\n%s", message, checks)
+			}
+		}
+	}
+}
+
+func TestCrossFieldParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import (
+	"context"
+	"time"
+)
+
+type Params struct {
+	Status  string     `+"`apivalidator:\"enum=user|admin\"`"+`
+	Reason  string     `+"`apivalidator:\"required_if=Status:admin\"`"+`
+	Email   string     `+"`apivalidator:\"paramname=email\"`"+`
+	Phone   *string    `+"`apivalidator:\"required_without=Email\"`"+`
+	Pass    string     `+"`apivalidator:\"required\"`"+`
+	Confirm string     `+"`apivalidator:\"eqfield=Pass\"`"+`
+	From    *time.Time `+"`apivalidator:\"paramname=from\"`"+`
+	To      time.Time  `+"`apivalidator:\"paramname=to,gtfield=From\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	sd, err := argumentStruct(t, pkg, "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{
+		`if params.Status == "admin" && params.Reason == "" {`,
+		`if params.Email == "" && params.Phone == nil {`,
+		`if sentConfirm && sentPass && params.Confirm != params.Pass {`,
+		`if sentTo && params.From != nil && !params.To.After(*params.From) {`,
+	} {
+		if checks := sd.Fields[i*2+1].GenChecks(); !strings.Contains(checks, expected) {
+			t.Errorf("%s expected in\n%s", expected, checks)
+		}
+	}
+
+	for _, c := range []struct{ tag, err string }{
+		{"required_if=Statuss:admin", "Params.Reason: required_if refers to unknown field Statuss"},
+		{"required_without=Reason", "Params.Reason: required_without refers to the field itself"},
+		{"required_if=Status", `required_if must be Field:value, got "Status"`},
+		{"required_if=Age:old", `Params.Reason: required_if value "old" is not a valid int`},
+		{"required_if=Tags:a", "required_if needs a field of a basic type, Tags is not"},
+		{"eqfield=Age", "eqfield needs Age to be of the same basic or time type"},
+		{"gtfield=Untagged", "gtfield refers to unknown field Untagged"},
+	} {
+		_, err := argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Status   string   `+"`apivalidator:\"paramname=status\"`"+`
+	Age      int      `+"`apivalidator:\"paramname=age\"`"+`
+	Tags     []string `+"`apivalidator:\"paramname=tag\"`"+`
+	Untagged string
+	Reason   string   `+"`apivalidator:\""+c.tag+"\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected %q, got %v", c.tag, c.err, err)
+		}
+	}
+
+	// the value of required_if is compared as parsed
+	sd, err = argumentStruct(t, checkSource(t, `package api
+
+type Params struct {
+	Admin  bool   `+"`apivalidator:\"paramname=admin\"`"+`
+	Level  *int   `+"`apivalidator:\"paramname=level\"`"+`
+	Reason string `+"`apivalidator:\"required_if=Admin:1\"`"+`
+	Note   string `+"`apivalidator:\"required_if=Level:010\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(in Params) error { return nil }
+`), "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{
+		`if params.Admin == true && params.Reason == "" {`,
+		`reason must me not empty when admin is true`,
+		`if params.Level != nil && *params.Level == 10 && params.Note == "" {`,
+		`note must me not empty when level is 10`,
+	} {
+		if checks := sd.Fields[2+i/2].GenChecks(); !strings.Contains(checks, expected) {
+			t.Errorf("%s expected in\n%s", expected, checks)
+		}
+	}
+}
+
+func TestPatternParams(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Slug string
+
+type Params struct {
+	Slug  Slug     `+"`apivalidator:\"required,pattern=^[a-z-]+$\"`"+`
+	Tags  []string `+"`apivalidator:\"paramname=tag,pattern=^[a-z-]+$\"`"+`
+	Phone *string  `+"`apivalidator:\"pattern='^[0-9]{5,15}$'\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/b"}
+func (a *Api) Again(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`)
+
+	imports := NewImports(pkg.Types)
+	funcCalls := collectFuncs(pkg, imports, NewDiagnostics(token.NewFileSet()))
+
+	// the same pattern of several fields and methods is compiled once
+	expected := "var paramPattern1 = regexp.MustCompile(`^[a-z-]+$`)\n" +
+		"var paramPattern2 = regexp.MustCompile(`^[0-9]{5,15}$`)\n"
+
+	if vars := compilePatterns(funcCalls, imports); vars != expected {
+		t.Errorf("unexpected pattern vars\n%s", vars)
+	}
+
+	for i, expected := range []string{
+		`if !paramPattern1.MatchString(string(params.Slug)) {`,
+		`if !paramPattern1.MatchString(item) {`,
+		`if !paramPattern2.MatchString(*params.Phone) {`,
+	} {
+		if checks := funcCalls[1].ArgumentStruct.Fields[i].GenChecks(); !strings.Contains(checks, expected) {
+			t.Errorf("%s expected in\n%s", expected, checks)
+		}
+	}
+
+	for _, c := range []struct{ tag, err string }{
+		{"pattern=^[a-z+$", "Params.Login: tag apivalidator:\"pattern=^[a-z+$\": pattern: error parsing regexp: missing closing ]"},
+		{"pattern=a(", "pattern: error parsing regexp: missing closing )"},
+	} {
+		_, err := argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Login string `+"`apivalidator:\""+c.tag+"\"`"+`
+	Age   int    `+"`apivalidator:\"pattern=^[0-9]+$\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+		if err == nil || !strings.Contains(err.Error(), c.err) || !strings.Contains(err.Error(), "Params.Age: pattern and format apply to strings only") {
+			t.Errorf("%s: expected %q, got %v", c.tag, c.err, err)
+		}
+	}
+}
+
+func TestNormalizeParams(t *testing.T) {
+	sd, err := argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Login string
+
+type Params struct {
+	Login Login    `+"`apivalidator:\"required,trim,lower\"`"+`
+	Tags  []string `+"`apivalidator:\"paramname=tag,split=,,collapse,upper\"`"+`
+	Age   *int     `+"`apivalidator:\"trim\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{
+		`formValue(r.Form, "login", "", "", false, "trim", "lower")`,
+		`formItems(r.Form, "tag", "", ",", "", "collapse", "upper")`,
+		`formValue(r.Form, "age", "", "", true, "trim")`,
+	} {
+		if assign := sd.Fields[i].GenParamAssign(); !strings.Contains(assign, expected) {
+			t.Errorf("%s expected in\n%s", expected, assign)
+		}
+	}
+
+	_, err = argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Age  int `+"`apivalidator:\"lower\"`"+`
+	Page int `+"`apivalidator:\"trim\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+	if expected := "Params.Age: collapse, lower and upper apply to strings and TextUnmarshaler types only"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import (
+	"context"
+	"time"
+)
+
+type Range struct {
+	From int `+"`json:\"from,omitempty\" apivalidator:\"min=0\"`"+`
+	To   int `+"`json:\"-\" apivalidator:\"max=10\"`"+`
+}
+
+type Hidden struct {
+	Note string `+"`apivalidator:\"paramname=note\"`"+`
+}
+
+type Params struct {
+	Login  string    `+"`json:\"user\" apivalidator:\"paramname=login,required\"`"+`
+	Tags   []string  `+"`apivalidator:\"paramname=tag\"`"+`
+	Ok     *bool     `+"`apivalidator:\"paramname=ok\"`"+`
+	Since  time.Time `+"`apivalidator:\"paramname=since\"`"+`
+	Age    Range     `+"`json:\"age_range\"`"+`
+	Secret string    `+"`json:\"-\" apivalidator:\"paramname=secret\"`"+`
+	Dash   string    `+"`json:\"-,\" apivalidator:\"paramname=dash\"`"+`
+	Inner  Hidden    `+"`json:\"-\"`"+`
+	Hidden `+"`json:\"-\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/b"}
+func (a *Api) Again(ctx context.Context, in *Params) (*Api, error) { return nil, nil }
+`)
+
+	funcCalls := collectFuncs(pkg, NewImports(pkg.Types), NewDiagnostics(token.NewFileSet()))
+
+	// both methods share the table of Params, the json:"-" fields and
+	// structs are left out
+	expected := `var paramJSON1 = map[string]jsonField{
+"user": {key: "login", kind: "string"},
+"tag": {key: "tag", kind: "string", slice: true},
+"ok": {key: "ok", kind: "boolean"},
+"since": {key: "since", kind: "string"},
+"age_range": {kind: "object"},
+"age_range.from": {key: "age.from", kind: "number"},
+"-": {key: "dash", kind: "string"},
+}
+
+`
+
+	if vars := compileJSONFields(funcCalls); vars != expected {
+		t.Errorf("unexpected JSON fields\n%s", vars)
+	}
+
+	if funcCalls[1].ArgumentStruct.JSONVar != "paramJSON1" {
+		t.Errorf("unexpected JSON var %s", funcCalls[1].ArgumentStruct.JSONVar)
+	}
+
+	_, err := argumentStruct(t, checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	Login string `+"`json:\"name\"`"+`
+	Name  string `+"`apivalidator:\"required\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+	if expected := "Params: JSON key name is bound to both Login and Name"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestParseJSONForm(t *testing.T) {
+	fields := map[string]jsonField{
+		"user":       {key: "login", kind: "string"},
+		"tag":        {key: "tag", kind: "string", slice: true},
+		"ok":         {key: "ok", kind: "boolean"},
+		"range":      {kind: "object"},
+		"range.from": {key: "range.from", kind: "number"},
+	}
+
+	parse := func(contentType, body string) (*http.Request, error) {
+		r := httptest.NewRequest(http.MethodPost, "/a?login=query&ok=false", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.ParseForm()
+
+		return r, parseJSONForm(httptest.NewRecorder(), r, fields)
+	}
+
+	r, err := parse("application/json; charset=utf-8", `{"user": "ivan", "tag": ["a", "b"], "range": {"from": 1e3}, "ok": null}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the body wins over the query, a null leaves the query value
+	if expected := (url.Values{"login": {"ivan"}, "tag": {"a", "b"}, "ok": {"false"}, "range.from": {"1e3"}}); !reflect.DeepEqual(r.Form, expected) {
+		t.Errorf("unexpected form %v", r.Form)
+	}
+
+	if r, err := parse("application/x-www-form-urlencoded", `user=ivan`); err != nil || r.Form.Get("login") != "query" {
+		t.Errorf("a form is left to ParseForm: %v, %v", r.Form, err)
+	}
+
+	if _, err := parse("application/problem+json", ``); err != nil {
+		t.Errorf("an empty body has no params: %v", err)
+	}
+
+	for body, expected := range map[string]string{
+		`{"user": "ivan",}`:       "invalid JSON body: invalid character '}' looking for beginning of object key string",
+		`{"user": "ivan"} x`:      "invalid JSON body: unexpected data after the object",
+		`"ivan"`:                  "JSON body must be an object",
+		`{"nick": "ivan"}`:        "unknown field nick",
+		`{"range": {"to": 1}}`:    "unknown field range.to",
+		`{"user": true}`:          "user must be a string",
+		`{"ok": "yes"}`:           "ok must be a boolean",
+		`{"tag": "a"}`:            "tag must be an array",
+		`{"tag": ["a", null]}`:    "tag must be an array of strings",
+		`{"range": []}`:           "range must be an object",
+		`{"range": {"from": ""}}`: "range.from must be a number",
+	} {
+		if _, err := parse("application/json", body); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected %q, got %v", body, expected, err)
+		}
+	}
+}
+
+func TestFileParams(t *testing.T) {
+	sd, err := argumentStruct(t, checkSource(t, `package api
+
+import (
+	"context"
+	"mime/multipart"
+)
+
+type Params struct {
+	Avatar *multipart.FileHeader   `+"`apivalidator:\"required,maxsize=1MB,contenttype=image/png|image/*\"`"+`
+	Docs   []*multipart.FileHeader `+"`apivalidator:\"paramname=doc,maxitems=2,maxsize=512\"`"+`
+	Note   *multipart.FileHeader   `+"`apivalidator:\"paramname=note\"`"+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a", "method": "POST"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range [][2]string{
+		{`params.Avatar = formFile(r, "avatar", "")`, "if params.Avatar != nil {\n" +
+			"if params.Avatar.Size > 1048576 {\nhandleServerError(w, http.StatusRequestEntityTooLarge, errors.New(\"avatar must be at most 1MB\"))\n\nreturn\n}\n" +
+			"if !validContentType(params.Avatar, \"image/png\", \"image/*\") {\nhandleBadRequest(w, \"avatar content type must be one of [image/png, image/*]\")\n\nreturn\n}\n}\n"},
+		{`params.Docs = formFiles(r, "doc", "")`, "for _, item := range params.Docs {\nif item.Size > 512 {"},
+		{`params.Note = formFile(r, "note", "")`, ""},
+	} {
+		f := sd.Fields[i]
+		if assign := f.GenParamAssign(); assign != expected[0] {
+			t.Errorf("%s: unexpected %s", f.Name, assign)
+		}
+
+		if checks := f.GenChecks(); !strings.Contains(checks, expected[1]) || expected[1] == "" && checks != "" {
+			t.Errorf("%s: %q expected in\n%s", f.Name, expected[1], checks)
+		}
+	}
+
+	// the checks of all errors mode report an oversize file as the others
+	if checks := sd.Fields[0].genChecks(true); !strings.Contains(checks, `errs = append(errs, FieldError{Field: "avatar", Rule: "maxsize", Message: "avatar must be at most 1MB"})`) {
+		t.Errorf("unexpected checks\n%s", checks)
+	}
+
+	for _, c := range []struct{ field, err string }{
+		{"File *multipart.FileHeader `apivalidator:\"min=1\"`", "Params.File: only paramname, required, maxsize, contenttype, minitems and maxitems apply to files"},
+		{"Files []*multipart.FileHeader `apivalidator:\"trim\"`", "Params.Files: only paramname, required, maxsize, contenttype, minitems and maxitems apply to files"},
+		{"Inner struct{ A int `apivalidator:\"required\"` } `apivalidator:\"maxsize=1MB\"`", "Params.Inner: only paramname applies to a nested struct"},
+		{"File multipart.FileHeader `apivalidator:\"required\"`", "Params.File: unsupported type mime/multipart.FileHeader"},
+		{"Name string `apivalidator:\"maxsize=1MB\"`", "Params.Name: maxsize and contenttype apply to *multipart.FileHeader fields only"},
+		{"File *multipart.FileHeader `apivalidator:\"maxitems=1\"`", "Params.File: minitems, maxitems and split apply to slices only"},
+		{"File *multipart.FileHeader `apivalidator:\"paramname=file\"`\n\tName string `apivalidator:\"required_without=File\"`", "Params.Name: required_without doesn't apply to files, File is one"},
+	} {
+		_, err := argumentStruct(t, checkSource(t, `package api
+
+import (
+	"context"
+	"mime/multipart"
+)
+
+type Params struct {
+	`+c.field+`
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a"}
+func (a *Api) Do(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`), "Do")
+
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: expected %q, got %v", c.field, c.err, err)
+		}
+	}
+}
+
+func TestMultipartForm(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("login", "ivan")
+
+	for _, name := range []string{"a.png", "b.PNG"} {
+		part, _ := mw.CreatePart(map[string][]string{
+			"Content-Disposition": {`form-data; name="doc[0]"; filename="` + name + `"`},
+			"Content-Type":        {"Image/PNG; q=1"},
+		})
+		part.Write([]byte("png"))
+	}
+
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/a", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.ParseForm()
+
+	if err := parseMultipartForm(httptest.NewRecorder(), r, 1, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Form.Get("login") != "ivan" {
+		t.Errorf("unexpected form %v", r.Form)
+	}
+
+	if files := formFiles(r, "doc.0", "doc[0]"); len(files) != 2 || formFile(r, "doc.0", "doc[0]") != files[0] {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	if file := formFile(r, "missing", ""); file != nil {
+		t.Errorf("unexpected file %v", file)
+	}
+
+	file := formFile(r, "doc.0", "doc[0]")
+	for allowed, expected := range map[string]bool{
+		"image/png":  true,
+		"image/*":    true,
+		"image/jpeg": false,
+		"text/*":     false,
+	} {
+		if validContentType(file, allowed) != expected {
+			t.Errorf("%s: expected %v", allowed, expected)
+		}
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/a", strings.NewReader("login=ivan"))
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+
+	if err := parseMultipartForm(httptest.NewRecorder(), r, 1, 1<<20); err == nil || !strings.HasPrefix(err.Error(), "invalid multipart body: ") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// the body is cut off at maxBody rather than written to temporary files
+	body.Reset()
+	mw = multipart.NewWriter(body)
+	part, _ := mw.CreateFormFile("doc", "a.png")
+	part.Write(bytes.Repeat([]byte("x"), 1<<10))
+	mw.Close()
+
+	r = httptest.NewRequest(http.MethodPost, "/a", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var tooLarge *http.MaxBytesError
+	if err := parseMultipartForm(httptest.NewRecorder(), r, 1, 512); !errors.As(err, &tooLarge) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestMaxBody(t *testing.T) {
+	src := `package api
+
+import "mime/multipart"
+
+type Params struct {
+	Avatar *multipart.FileHeader   ` + "`apivalidator:\"maxsize=2MB\"`" + `
+	Docs   []*multipart.FileHeader ` + "`apivalidator:\"maxsize=64KB\"`" + `
+}
+
+type Plain struct {
+	Doc *multipart.FileHeader
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a", "method": "POST"}
+func (a *Api) Default(in Params) error { return nil }
+
+// apigen:api {"url": "/b", "method": "POST", "multipart_memory": 1024, "max_body": 4096}
+func (a *Api) Annotated(in Params) error { return nil }
+
+// apigen:api {"url": "/c", "method": "POST", "multipart_memory": 1024}
+func (a *Api) NoMaxSize(in Plain) error { return nil }
+`
+
+	for _, c := range []struct {
+		opts     genOptions
+		expected []string
+	}{
+		{genOptions{}, []string{
+			"parseMultipartForm(w, r, 33554432, 35651584)",
+			"parseMultipartForm(w, r, 1024, 4096)",
+			"parseMultipartForm(w, r, 1024, 33555456)",
+		}},
+		// the annotation wins over the flag
+		{genOptions{MaxBody: 8192}, []string{
+			"parseMultipartForm(w, r, 33554432, 8192)",
+			"parseMultipartForm(w, r, 1024, 4096)",
+			"parseMultipartForm(w, r, 1024, 8192)",
+		}},
+	} {
+		out := string(generatePackage(checkSource(t, src), NewDiagnostics(token.NewFileSet()), c.opts))
+
+		for _, expected := range c.expected {
+			if !strings.Contains(out, expected) {
+				t.Errorf("%+v: expected %s in\n%s", c.opts, expected, out)
+			}
+		}
+	}
+}
+
+func TestAllErrorsBind(t *testing.T) {
+	src := `package api
+
+import "context"
+
+type Params struct {
+	Login string ` + "`apivalidator:\"required,min=3\"`" + `
+	Age   int    ` + "`apivalidator:\"min=0\"`" + `
+	Ids   []int  ` + "`apivalidator:\"paramname=id\"`" + `
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a", "all_errors": true}
+func (a *Api) All(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/b"}
+func (a *Api) First(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+`
+
+	for _, c := range []struct {
+		allErrors bool
+		handlers  int
+	}{
+		{false, 1},
+		{true, 2},
+	} {
+		out := string(generatePackage(checkSource(t, src), NewDiagnostics(token.NewFileSet()), genOptions{AllErrors: c.allErrors}))
+
+		if n := strings.Count(out, "handleFieldErrors(w, errs)\n"); n != c.handlers {
+			t.Errorf("all-errors %v: expected %d handlers reporting all errors, got %d", c.allErrors, c.handlers, n)
+		}
+	}
+
+	pkg := checkSource(t, src)
+	funcCalls := collectFuncs(pkg, NewImports(pkg.Types), NewDiagnostics(token.NewFileSet()))
+	sd := funcCalls[0].ArgumentStruct
+	sd.AllErrors = true
+	bind := sd.GenBind()
+
+	for _, expected := range []string{
+		"var errs []FieldError\nvar invalidAge bool\n",
+		`errs = append(errs, FieldError{Field: "age", Rule: "type", Message: parseError("age", "int", err)})` + "\ninvalidAge = true\n",
+		// the items of a slice without checks aren't flagged
+		`errs = append(errs, FieldError{Field: "id", Rule: "type", Message: parseError("id", "int", err)})` + "\n\nbreak\n",
+		`errs = append(errs, FieldError{Field: "login", Rule: "min", Message: "login len must be >= 3"})`,
+		"if !invalidAge {\nfunc() {\n",
+		"if len(errs) > 0 {\nhandleFieldErrors(w, errs)\n",
+	} {
+		if !strings.Contains(bind, expected) {
+			t.Errorf("%s expected in\n%s", expected, bind)
+		}
+	}
+}
+
+func TestMethodSignatures(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Params struct {
+	ID int `+"`apivalidator:\"required\"`"+`
+}
+
+type Api struct{}
+
+type Store[K comparable, V any] struct{}
+
+// apigen:api {"url": "/full"}
+func (a *Api) Full(ctx context.Context, in Params) (*Api, error) { return nil, nil }
+
+// apigen:api {"url": "/nocontext"}
+func (a Api) NoContext(in *Params) (Api, error) { return a, nil }
+
+// apigen:api {"url": "/noparams"}
+func (a *Api) NoParams(ctx context.Context) error { return nil }
+
+// apigen:api {"url": "/nothing"}
+func (a *Api) Nothing() error { return nil }
+
+// apigen:api {"url": "/get"}
+func (s *Store[K, V]) Get(in Params) (V, error) { var v V; return v, nil }
+
+// apigen:api {"url": "/twostructs"}
+func (a *Api) TwoStructs(in Params, other Params) error { return nil }
+
+// apigen:api {"url": "/noerror"}
+func (a *Api) NoError(ctx context.Context) *Api { return a }
+
+// apigen:api {"url": "/noresults"}
+func (a *Api) NoResults() {}
+
+// apigen:api {"url": "/variadic"}
+func (a *Api) Variadic(ctx context.Context, in ...Params) error { return nil }
+
+// apigen:api {"url": "/errorfirst"}
+func (a *Api) ErrorFirst() (error, *Api) { return nil, nil }
+
+// apigen:api {"url": "/notstruct"}
+func (a *Api) NotStruct(ctx context.Context, id int) error { return nil }
+`)
+
+	imports := NewImports(pkg.Types)
+	funcs := map[string]*FuncDef{}
+	errs := map[string]error{}
+
+	for _, d := range pkg.Files[0].Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok {
+			fc := &FuncDef{}
+			errs[fd.Name.Name] = inspectFuncSignature(pkg, fd, fc, imports)
+			funcs[fd.Name.Name] = fc
+		}
+	}
+
+	for _, c := range []struct {
+		method, recv, args, result string
+	}{
+		{"Full", "Api", "r.Context(), params", "Api"},
+		{"NoContext", "Api", "params", "Api"},
+		{"NoParams", "Api", "r.Context()", ""},
+		{"Nothing", "Api", "", ""},
+		{"Get", "Store[K, V]", "params", "V"},
+	} {
+		if errs[c.method] != nil {
+			t.Errorf("%s: %v", c.method, errs[c.method])
+
+			continue
+		}
+
+		fc := funcs[c.method]
+		if fc.ReceiverType != c.recv || fc.CallArgs() != c.args || fc.ResulTypeName != c.result {
+			t.Errorf("%s: unexpected %q %q %q", c.method, fc.ReceiverType, fc.CallArgs(), fc.ResulTypeName)
+		}
+	}
+
+	for _, method := range []string{"TwoStructs", "NoError", "NoResults", "Variadic", "ErrorFirst", "NotStruct"} {
+		if err := errs[method]; err == nil || !strings.HasPrefix(err.Error(), "Api."+method+": ") {
+			t.Errorf("%s: error naming the method expected, got %v", method, err)
+		}
+	}
+}
+
+func TestValidateHook(t *testing.T) {
+	pkg := checkSource(t, `package api
+
+import "context"
+
+type Plain struct{}
+
+func (p Plain) Validate() error { return nil }
+
+type WithContext struct{}
+
+func (p *WithContext) Validate(ctx context.Context) error { return nil }
+
+type Promoted struct {
+	Plain
+}
+
+type None struct{}
+
+type Wrong struct{}
+
+func (p Wrong) Validate() bool { return true }
+
+type Api struct{}
+
+// apigen:api {"url": "/plain"}
+func (a *Api) DoPlain(in Plain) error { return nil }
+
+// apigen:api {"url": "/ctx"}
+func (a *Api) DoWithContext(in *WithContext) error { return nil }
+
+// apigen:api {"url": "/promoted"}
+func (a *Api) DoPromoted(in Promoted) error { return nil }
+
+// apigen:api {"url": "/none"}
+func (a *Api) DoNone(in None) error { return nil }
+
+// apigen:api {"url": "/wrong"}
+func (a *Api) DoWrong(in Wrong) error { return nil }
+`)
+
+	imports := NewImports(pkg.Types)
+
+	for _, d := range pkg.Files[0].Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Recv == nil || fd.Name.Name == "Validate" {
+			continue
+		}
+
+		fc := &FuncDef{}
+		err := inspectFuncSignature(pkg, fd, fc, imports)
+
+		expected := map[string]string{
+			"DoPlain":       "params.Validate()",
+			"DoWithContext": "params.Validate(r.Context())",
+			"DoPromoted":    "params.Validate()",
+		}[fd.Name.Name]
+
+		if fd.Name.Name == "DoWrong" {
+			if err == nil || !strings.Contains(err.Error(), "Wrong.Validate: the hook must be") {
+				t.Errorf("wrong Validate expected to fail, got %v", err)
+			}
+
+			continue
+		}
+
+		if err != nil || fc.ValidateCall != expected {
+			t.Errorf("%s: expected %q, got %q, %v", fd.Name.Name, expected, fc.ValidateCall, err)
+		}
+	}
+}
+
+func TestFlagNames(t *testing.T) {
+	src := `package api
+
+type ApiError struct {
+	HTTPStatus int
+	Err        error
+}
+
+func (ae ApiError) Error() string { return ae.Err.Error() }
+
+type Filter struct {
+	Age int ` + "`apivalidator:\"min=1\"`" + `
+}
+
+type Params struct {
+	Filter     Filter
+	FilterAge  int ` + "`apivalidator:\"paramname=fa,min=1\"`" + `
+	Filter_Age int ` + "`apivalidator:\"paramname=fa2,min=1\"`" + `
+}
+
+type Api struct{}
+
+// apigen:api {"url": "/a", "all_errors": true}
+func (a *Api) Do(in Params) error { return nil }
+`
+
+	diags := NewDiagnostics(token.NewFileSet())
+	out := generatePackage(checkSource(t, src), diags, genOptions{})
+	if out == nil {
+		t.Fatalf("nothing generated: %v", diags.list)
+	}
+
+	for _, expected := range []string{"var sentFilter_Age bool\n", "var sentFilterAge bool\n", "var sentFilter_Age_2 bool\n", "var invalidFilter_Age_2 bool\n"} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("%q expected in\n%s", expected, out)
+		}
+	}
+
+	// the generated file compiles along with the package
+	fileSet := token.NewFileSet()
+
+	var files []*ast.File
+	for name, code := range map[string]string{"api.go": src, "api_handlers.go": string(out)} {
+		file, err := parser.ParseFile(fileSet, name, code, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, file)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fileSet, "source", nil)}
+	if _, err := conf.Check("api", fileSet, files, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// сообщения об ошибках строятся из правил тега, а не из таблицы готовых строк,
+// поэтому не зависят от пришедшего значения
+func TestMyApiMessages(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+
+	cases := []Case{
+		Case{
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "login=short",
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "login len must be >= 10",
+			},
+		},
+		Case{
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "login=mr.moderator2&age=1000",
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "age must be <= 128",
+			},
+		},
+		Case{
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "login=mr.moderator2&status=root",
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "status must be one of [user, moderator, admin]",
+			},
+		},
+	}
+
+	runTests(t, ts, cases)
+
+	ts = httptest.NewServer(NewOtherApi())
+
+	runTests(t, ts, []Case{
+		Case{
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  "username=I3apBap&level=1&class=paladin&account_name=Vasily",
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "class must be one of [warrior, sorcerer, rouge]",
+			},
+		},
+	})
+}